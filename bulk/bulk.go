@@ -0,0 +1,175 @@
+// Package bulk 批量发送个性化邮件（邮件合并）：以模板和收件人数据（结构体切片、CSV 文件或迭代器）
+// 为每一行渲染一封邮件，经 SMTPSenderPool 的多个连接并发发送，并逐个收件人记录发送结果。
+package bulk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/JiuYu77/go-email/smtp"
+)
+
+// Sender 批量发送邮件。
+type Sender struct {
+	// Pool 发送邮件使用的连接池。
+	Pool *smtp.SMTPSenderPool
+	// Template 邮件模板。
+	Template *Template
+	// Workers 同时发送邮件的数量，默认与连接池的 MaxSize 相同。
+	Workers int
+	// RetryPolicy 每封邮件的重试策略，nil 时使用连接池的 SMTP.RetryPolicy。
+	RetryPolicy *smtp.RetryPolicy
+	// Report 记录每个收件人的发送结果，nil 表示不记录。
+	Report Reporter
+}
+
+// Summary 一次批量发送的统计。
+type Summary struct {
+	Rows   int `json:"rows"`   // 读取的数据行数
+	Sent   int `json:"sent"`   // 发送成功的收件人数
+	Failed int `json:"failed"` // 发送失败的收件人数
+}
+
+// Send 读取 src 的每一行数据，渲染邮件并发送，等待全部发送完毕后返回统计。
+// 单封邮件渲染或发送失败只记录在报告中，不中断发送；
+// src 产生错误、写入报告失败或 ctx 结束时停止读取，返回该错误。
+func (s *Sender) Send(ctx context.Context, src Source) (Summary, error) {
+	if s.Pool == nil || s.Template == nil {
+		return Summary{}, errors.New("goemail: bulk: Pool and Template are required")
+	}
+	d := smtp.NewDispatcher(s.Pool, smtp.DispatcherConfig{
+		Workers:     s.Workers,
+		RetryPolicy: s.RetryPolicy,
+	})
+
+	r := &recorder{report: s.Report}
+	rows := 0
+	var err error
+	for data, srcErr := range src {
+		if srcErr != nil {
+			err = srcErr
+			break
+		}
+		rows++
+		row := rows
+
+		m, renderErr := s.Template.Render(data)
+		if renderErr != nil {
+			to, _ := s.Template.Recipients(data)
+			r.failed(row, to, renderErr)
+			continue
+		}
+		env, envErr := m.Envelope()
+		if envErr != nil {
+			to, _ := s.Template.Recipients(data)
+			r.failed(row, to, envErr)
+			continue
+		}
+		f, submitErr := d.Submit(ctx, m)
+		if submitErr != nil {
+			err = submitErr
+			break
+		}
+		f.OnDone(func(result *smtp.SendResult, sendErr error) {
+			r.done(row, env.To, result, sendErr)
+		})
+		if err = r.error(); err != nil {
+			break
+		}
+	}
+
+	if shutdownErr := d.Shutdown(ctx); err == nil {
+		err = shutdownErr
+	}
+	if err == nil {
+		err = r.error()
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.sum.Rows = rows
+	return r.sum, err
+}
+
+// recorder 汇总发送结果并写入报告，可以在多个 worker 中同时调用。
+type recorder struct {
+	report Reporter
+
+	mtx sync.Mutex
+	sum Summary
+	err error // 第一次写入报告失败的错误
+}
+
+func (r *recorder) error() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.err
+}
+
+// failed 记录渲染失败的一行，无法得到收件人时 to 为空。
+func (r *recorder) failed(row int, to []string, err error) {
+	if len(to) == 0 {
+		to = []string{""}
+	}
+	now := time.Now()
+	outcomes := make([]*Outcome, len(to))
+	for i, addr := range to {
+		outcomes[i] = &Outcome{Row: row, Recipient: addr, Status: Failed, Error: err.Error(), Time: now}
+	}
+	r.record(outcomes)
+}
+
+// done 记录一封邮件的发送结果：有每个收件人的响应时逐个记录，否则所有收件人都记为 err。
+func (r *recorder) done(row int, to []string, result *smtp.SendResult, err error) {
+	now := time.Now()
+	var outcomes []*Outcome
+	if result != nil && len(result.Accepted)+len(result.TempFailed)+len(result.PermFailed) > 0 {
+		for _, st := range result.Accepted {
+			outcomes = append(outcomes, outcome(row, st, Sent, err, now))
+		}
+		for _, st := range result.Failed() {
+			outcomes = append(outcomes, outcome(row, st, Failed, nil, now))
+		}
+	} else {
+		for _, addr := range to {
+			o := &Outcome{Row: row, Recipient: addr, Status: Sent, Time: now}
+			if err != nil {
+				o.Status, o.Error = Failed, err.Error()
+			}
+			outcomes = append(outcomes, o)
+		}
+	}
+	r.record(outcomes)
+}
+
+// outcome 由收件人的响应生成结果；收件人已接收但邮件内容发送失败时，err 不为 nil，记为失败。
+func outcome(row int, st smtp.RecipientStatus, status Status, err error, now time.Time) *Outcome {
+	o := &Outcome{
+		Row: row, Recipient: st.Address, Status: status,
+		Code: st.Code, EnhancedCode: st.EnhancedCode, Message: st.Message, Time: now,
+	}
+	if err != nil {
+		o.Status, o.Error = Failed, err.Error()
+	} else if status == Failed {
+		o.Error = (&smtp.SMTPError{Command: "RCPT", Code: st.Code, EnhancedCode: st.EnhancedCode, Message: st.Message}).Error()
+	}
+	return o
+}
+
+func (r *recorder) record(outcomes []*Outcome) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, o := range outcomes {
+		if o.Status == Sent {
+			r.sum.Sent++
+		} else {
+			r.sum.Failed++
+		}
+		if r.report == nil || r.err != nil {
+			continue
+		}
+		r.err = r.report.Report(o)
+	}
+}
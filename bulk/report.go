@@ -0,0 +1,81 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Status 单个收件人的发送结果。
+type Status string
+
+const (
+	// Sent 服务器已接收。
+	Sent Status = "sent"
+	// Failed 渲染失败、发送失败或被服务器拒绝。
+	Failed Status = "failed"
+)
+
+// Outcome 单个收件人的发送结果，报告中的一行。
+type Outcome struct {
+	// Row 数据的行号，从 1 开始（CSV 不计列名行）。
+	Row       int    `json:"row"`
+	Recipient string `json:"recipient"`
+	Status    Status `json:"status"`
+	// Code、EnhancedCode、Message 服务器对该收件人的响应，没有收到响应时为空。
+	Code         int    `json:"code,omitempty"`
+	EnhancedCode string `json:"enhanced_code,omitempty"`
+	Message      string `json:"message,omitempty"`
+	// Error 失败的原因。
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Reporter 记录每个收件人的发送结果，Sender 不会同时调用 Report。
+type Reporter interface {
+	Report(o *Outcome) error
+}
+
+// CSVReport 将发送结果写为 CSV，第一行为列名。
+type CSVReport struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVReport 创建将发送结果以 CSV 格式写入 w 的 Reporter。
+func NewCSVReport(w io.Writer) *CSVReport {
+	return &CSVReport{w: csv.NewWriter(w)}
+}
+
+func (r *CSVReport) Report(o *Outcome) error {
+	if !r.header {
+		r.header = true
+		r.w.Write([]string{"row", "recipient", "status", "code", "enhanced_code", "message", "error", "time"})
+	}
+	code := ""
+	if o.Code != 0 {
+		code = strconv.Itoa(o.Code)
+	}
+	r.w.Write([]string{
+		strconv.Itoa(o.Row), o.Recipient, string(o.Status), code, o.EnhancedCode, o.Message, o.Error,
+		o.Time.Format(time.RFC3339),
+	})
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// JSONReport 将发送结果写为 JSON Lines，每行一个 Outcome。
+type JSONReport struct {
+	enc *json.Encoder
+}
+
+// NewJSONReport 创建将发送结果以 JSON Lines 格式写入 w 的 Reporter。
+func NewJSONReport(w io.Writer) *JSONReport {
+	return &JSONReport{enc: json.NewEncoder(w)}
+}
+
+func (r *JSONReport) Report(o *Outcome) error {
+	return r.enc.Encode(o)
+}
@@ -0,0 +1,87 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"strings"
+)
+
+// Source 收件人数据的来源，依次产生每一行数据；产生错误时 Sender.Send 停止读取并返回该错误。
+type Source = iter.Seq2[any, error]
+
+// FromSlice 以切片 rows 中的每个元素（如结构体）为一行数据。
+func FromSlice[T any](rows []T) Source {
+	return func(yield func(any, error) bool) {
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FromSeq 以迭代器 seq 产生的每个值为一行数据，可用于从数据库等来源逐行读取。
+func FromSeq[T any](seq iter.Seq[T]) Source {
+	return func(yield func(any, error) bool) {
+		for row := range seq {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FromCSV 从 CSV 读取数据：第一行为列名，之后每一行为一个 map[string]string（列名 -> 值）。
+func FromCSV(r io.Reader) Source {
+	return func(yield func(any, error) bool) {
+		cr := csv.NewReader(r)
+		cr.TrimLeadingSpace = true
+		header, err := cr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			yield(nil, err)
+			return
+		}
+		// 去除 UTF-8 BOM
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		for i := range header {
+			header[i] = strings.TrimSpace(header[i])
+		}
+
+		for {
+			record, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			row := make(map[string]string, len(header))
+			for i, name := range header {
+				row[name] = record[i]
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FromCSVFile 与 FromCSV 相同，从文件 name 读取数据。
+func FromCSVFile(name string) Source {
+	return func(yield func(any, error) bool) {
+		f, err := os.Open(name)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer f.Close()
+		FromCSV(f)(yield)
+	}
+}
@@ -0,0 +1,122 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/JiuYu77/go-email/smtp"
+)
+
+// TemplateConfig 邮件模板。To、Subject、Text 使用 text/template 语法，HTML 使用 html/template 语法，
+// 以每一行数据为参数渲染，如 "{{.Email}}"（结构体字段）或 "{{.email}}"（CSV 列名）。
+type TemplateConfig struct {
+	// From 发件人地址，FromName 发件人名称，不使用模板。
+	From     string
+	FromName string
+	// To 收件人地址的模板，必填；渲染结果可以包含多个以逗号分隔的地址。
+	To string
+	// Subject 邮件主题的模板。
+	Subject string
+	// Text 纯文本正文的模板。
+	Text string
+	// HTML HTML 正文的模板；与 Text 同时设置时，HTML 作为 Text 的替代版本（multipart/alternative）。
+	HTML string
+	// Settings 创建邮件时使用的设置，如 smtp.SetCharset。
+	Settings []smtp.MessageSetting
+}
+
+// Template 解析后的邮件模板，可以在多个 goroutine 中同时使用。
+type Template struct {
+	config  TemplateConfig
+	to      *texttemplate.Template
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// ParseTemplate 解析邮件模板。
+func ParseTemplate(config TemplateConfig) (*Template, error) {
+	if config.To == "" {
+		return nil, errors.New("goemail: bulk: To template is required")
+	}
+	if config.Text == "" && config.HTML == "" {
+		return nil, errors.New("goemail: bulk: Text or HTML template is required")
+	}
+
+	t := &Template{config: config}
+	var err error
+	if t.to, err = texttemplate.New("to").Option("missingkey=error").Parse(config.To); err != nil {
+		return nil, err
+	}
+	if t.subject, err = texttemplate.New("subject").Option("missingkey=error").Parse(config.Subject); err != nil {
+		return nil, err
+	}
+	if config.Text != "" {
+		if t.text, err = texttemplate.New("text").Option("missingkey=error").Parse(config.Text); err != nil {
+			return nil, err
+		}
+	}
+	if config.HTML != "" {
+		if t.html, err = htmltemplate.New("html").Option("missingkey=error").Parse(config.HTML); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Render 以 data 渲染出一封邮件。
+func (t *Template) Render(data any) (*smtp.Message, error) {
+	to, err := t.Recipients(data)
+	if err != nil {
+		return nil, err
+	}
+	var subject bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+
+	m := smtp.NewMessage(t.config.Settings...)
+	m.SetFrom(t.config.From, t.config.FromName)
+	m.SetTo(to)
+	m.SetSubject(subject.String())
+	if t.text != nil {
+		var body bytes.Buffer
+		if err := t.text.Execute(&body, data); err != nil {
+			return nil, err
+		}
+		m.SetBody("text/plain", body.String())
+	}
+	if t.html != nil {
+		var body bytes.Buffer
+		if err := t.html.Execute(&body, data); err != nil {
+			return nil, err
+		}
+		if t.text != nil {
+			m.AddAlternative("text/html", body.String())
+		} else {
+			m.SetBody("text/html", body.String())
+		}
+	}
+	return m, nil
+}
+
+// Recipients 以 data 渲染收件人地址。
+func (t *Template) Recipients(data any) ([]string, error) {
+	var buf bytes.Buffer
+	if err := t.to.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	var to []string
+	for addr := range strings.SplitSeq(buf.String(), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	if len(to) == 0 {
+		return nil, smtp.ErrNoRecipients
+	}
+	return to, nil
+}
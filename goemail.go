@@ -1,10 +1,16 @@
 package goemail
 
 import (
+	"context"
+	"io"
+	"iter"
+	gosmtp "net/smtp"
 	"time"
 
+	"github.com/JiuYu77/go-email/bulk"
 	"github.com/JiuYu77/go-email/cache"
 	logx "github.com/JiuYu77/go-email/log"
+	"github.com/JiuYu77/go-email/queue"
 	"github.com/JiuYu77/go-email/smtp"
 	"github.com/JiuYu77/go-email/utils"
 	"github.com/JiuYu77/go-email/verifier"
@@ -12,16 +18,76 @@ import (
 
 type (
 	// SMTP
-	SMTP           = smtp.SMTP
-	SMTPConfig     = smtp.SMTPConfig
-	SMTPSender     = smtp.SMTPSender
-	Message        = smtp.Message
-	MessageSetting = smtp.MessageSetting
-	PartSetting    = smtp.PartSetting
-	FileSetting    = smtp.FileSetting
-	Encoding       = smtp.Encoding
-	Copier         = smtp.Copier
-	Header         = smtp.Header
+	SMTP                 = smtp.SMTP
+	SMTPConfig           = smtp.SMTPConfig
+	SMTPSender           = smtp.SMTPSender
+	Envelope             = smtp.Envelope
+	DSN                  = smtp.DSN
+	DSNReturn            = smtp.DSNReturn
+	DSNNotify            = smtp.DSNNotify
+	SendResult           = smtp.SendResult
+	RecipientStatus      = smtp.RecipientStatus
+	SMTPError            = smtp.SMTPError
+	PartialDeliveryError = smtp.PartialDeliveryError
+	SMTPAuthType         = smtp.SMTPAuthType
+	SMTPSenderPool       = smtp.SMTPSenderPool
+	PoolConfig           = smtp.PoolConfig
+	PoolStats            = smtp.PoolStats
+	Throttle             = smtp.Throttle
+	ThrottleMode         = smtp.ThrottleMode
+	RateLimit            = smtp.RateLimit
+	RetryPolicy          = smtp.RetryPolicy
+	RetryAttempt         = smtp.RetryAttempt
+	Dispatcher           = smtp.Dispatcher
+	DispatcherConfig     = smtp.DispatcherConfig
+	Future               = smtp.Future
+	Interceptor          = smtp.Interceptor
+	Transport            = smtp.Transport
+	SendRequest          = smtp.SendRequest
+	SendFunc             = smtp.SendFunc
+	TLSPolicy            = smtp.TLSPolicy
+	Dialer               = smtp.Dialer
+	SourceAddr           = smtp.SourceAddr
+	IPVersion            = smtp.IPVersion
+	SOCKS5Dialer         = smtp.SOCKS5Dialer
+	HTTPProxyDialer      = smtp.HTTPProxyDialer
+	TokenSource          = smtp.TokenSource
+	TokenSourceContext   = smtp.TokenSourceContext
+	StaticTokenSource    = smtp.StaticTokenSource
+	RefreshTokenSource   = smtp.RefreshTokenSource
+	Resolver             = smtp.Resolver
+	DirectSender         = smtp.DirectSender
+	DomainResult         = smtp.DomainResult
+	FailoverSender       = smtp.FailoverSender
+	FailoverResult       = smtp.FailoverResult
+	RelayStatus          = smtp.RelayStatus
+	Balancer             = smtp.Balancer
+	BalanceStrategy      = smtp.BalanceStrategy
+	Account              = smtp.Account
+	AccountUsage         = smtp.AccountUsage
+	Message              = smtp.Message
+	MessageSetting       = smtp.MessageSetting
+	PartSetting          = smtp.PartSetting
+	FileSetting          = smtp.FileSetting
+	Encoding             = smtp.Encoding
+	Copier               = smtp.Copier
+	Header               = smtp.Header
+	// queue
+	Queue       = queue.Queue
+	QueueConfig = queue.Config
+	QueueEntry  = queue.Entry
+	QueueState  = queue.State
+	// bulk
+	BulkSender         = bulk.Sender
+	BulkSummary        = bulk.Summary
+	BulkTemplate       = bulk.Template
+	BulkTemplateConfig = bulk.TemplateConfig
+	BulkSource         = bulk.Source
+	BulkOutcome        = bulk.Outcome
+	BulkStatus         = bulk.Status
+	BulkReporter       = bulk.Reporter
+	CSVReport          = bulk.CSVReport
+	JSONReport         = bulk.JSONReport
 	// verifier
 	Config           = verifier.Config
 	Verifier         = verifier.Verifier
@@ -32,11 +98,160 @@ type (
 	Cache[T CacheValue] = cache.Cache[T]
 )
 
+var (
+	// SMTP
+	ErrSMTPUTF8Unsupported = smtp.ErrSMTPUTF8Unsupported
+	ErrNotConnected        = smtp.ErrNotConnected
+	ErrConnectionLost      = smtp.ErrConnectionLost
+	ErrNoRecipients        = smtp.ErrNoRecipients
+	ErrMissingFrom         = smtp.ErrMissingFrom
+	ErrNoAuthMechanism     = smtp.ErrNoAuthMechanism
+	ErrTLSRequired         = smtp.ErrTLSRequired
+	ErrInsecureAuth        = smtp.ErrInsecureAuth
+	ErrFingerprintMismatch = smtp.ErrFingerprintMismatch
+	ErrLMTPAuth            = smtp.ErrLMTPAuth
+	ErrNullMX              = smtp.ErrNullMX
+	ErrNoMailHost          = smtp.ErrNoMailHost
+	ErrNoRelayAvailable    = smtp.ErrNoRelayAvailable
+	ErrQuotaExhausted      = smtp.ErrQuotaExhausted
+	ErrPoolClosed          = smtp.ErrPoolClosed
+	ErrPoolTimeout         = smtp.ErrPoolTimeout
+	ErrRateLimited         = smtp.ErrRateLimited
+	ErrDispatcherClosed    = smtp.ErrDispatcherClosed
+	ErrDispatcherFull      = smtp.ErrDispatcherFull
+	// queue
+	ErrQueueEntryNotFound = queue.ErrNotFound
+	ErrQueueClosed        = queue.ErrClosed
+)
+
+// SMTP errors
+func IsTemporary(err error) bool {
+	return smtp.IsTemporary(err)
+}
+func IsPermanent(err error) bool {
+	return smtp.IsPermanent(err)
+}
+func IsAuthFailure(err error) bool {
+	return smtp.IsAuthFailure(err)
+}
+
 // SMTP
 func NewSMTP(host string, port int, username, password, from string) *SMTP {
 	return smtp.NewSMTP(host, port, username, password, from)
 }
 
+// SMTP auth
+func LoginAuth(username, password, host string) gosmtp.Auth {
+	return smtp.LoginAuth(username, password, host)
+}
+func XOAuth2Auth(username, token string) gosmtp.Auth {
+	return smtp.XOAuth2Auth(username, token)
+}
+func XOAuth2Auth1(username string, source TokenSource) gosmtp.Auth {
+	return smtp.XOAuth2Auth1(username, source)
+}
+func OAuthBearerAuth(username, host string, port int, source TokenSource) gosmtp.Auth {
+	return smtp.OAuthBearerAuth(username, host, port, source)
+}
+func NewRefreshTokenSource(tokenURL, clientID, clientSecret, refreshToken string) *RefreshTokenSource {
+	return smtp.NewRefreshTokenSource(tokenURL, clientID, clientSecret, refreshToken)
+}
+func ScramSHA1Auth(username, password string) gosmtp.Auth {
+	return smtp.ScramSHA1Auth(username, password)
+}
+func ScramSHA256Auth(username, password string) gosmtp.Auth {
+	return smtp.ScramSHA256Auth(username, password)
+}
+func ScramSHA256PlusAuth(username, password string) gosmtp.Auth {
+	return smtp.ScramSHA256PlusAuth(username, password)
+}
+
+// SMTP dialer
+func NewSOCKS5Dialer(proxyAddr, username, password string) *SOCKS5Dialer {
+	return smtp.NewSOCKS5Dialer(proxyAddr, username, password)
+}
+func NewHTTPProxyDialer(proxyAddr, username, password string) *HTTPProxyDialer {
+	return smtp.NewHTTPProxyDialer(proxyAddr, username, password)
+}
+func NewProxyDialer(proxyURL string) (Dialer, error) {
+	return smtp.NewProxyDialer(proxyURL)
+}
+
+// DirectSender
+func NewDirectSender(localName string) *DirectSender {
+	return smtp.NewDirectSender(localName)
+}
+
+// FailoverSender
+func NewFailoverSender(configs ...*SMTPConfig) *FailoverSender {
+	return smtp.NewFailoverSender(configs...)
+}
+func NewFailoverSender1(smtps ...*SMTP) *FailoverSender {
+	return smtp.NewFailoverSender1(smtps...)
+}
+
+// Balancer
+func NewAccount(config *SMTPConfig, dailyQuota, hourlyQuota int) *Account {
+	return smtp.NewAccount(config, dailyQuota, hourlyQuota)
+}
+func NewBalancer(stateFile string, accounts ...*Account) (*Balancer, error) {
+	return smtp.NewBalancer(stateFile, accounts...)
+}
+
+// RetryPolicy
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return smtp.NewRetryPolicy(maxAttempts)
+}
+func IsRetryable(err error) bool {
+	return smtp.IsRetryable(err)
+}
+
+// Throttle
+func NewThrottle(global RateLimit, mode ThrottleMode) *Throttle {
+	return smtp.NewThrottle(global, mode)
+}
+
+// Interceptor
+func BeforeSend(fn func(ctx context.Context, req *SendRequest) error) Interceptor {
+	return smtp.BeforeSend(fn)
+}
+func AfterSend(fn func(ctx context.Context, req *SendRequest, result *SendResult, err error, elapsed time.Duration)) Interceptor {
+	return smtp.AfterSend(fn)
+}
+
+// Dispatcher
+func NewDispatcher(pool *SMTPSenderPool, config DispatcherConfig) *Dispatcher {
+	return smtp.NewDispatcher(pool, config)
+}
+
+// Queue
+func NewQueue(config QueueConfig) (*Queue, error) {
+	return queue.New(config)
+}
+
+// bulk
+func ParseBulkTemplate(config BulkTemplateConfig) (*BulkTemplate, error) {
+	return bulk.ParseTemplate(config)
+}
+func BulkFromSlice[T any](rows []T) BulkSource {
+	return bulk.FromSlice(rows)
+}
+func BulkFromSeq[T any](seq iter.Seq[T]) BulkSource {
+	return bulk.FromSeq(seq)
+}
+func BulkFromCSV(r io.Reader) BulkSource {
+	return bulk.FromCSV(r)
+}
+func BulkFromCSVFile(name string) BulkSource {
+	return bulk.FromCSVFile(name)
+}
+func NewCSVReport(w io.Writer) *CSVReport {
+	return bulk.NewCSVReport(w)
+}
+func NewJSONReport(w io.Writer) *JSONReport {
+	return bulk.NewJSONReport(w)
+}
+
 // SMTPSender
 func NewSMTPSender(s *SMTP) *SMTPSender {
 	return smtp.NewSMTPSender(s)
@@ -45,10 +260,24 @@ func NewSMTPSender1(client *smtp.Client, s *SMTP) *SMTPSender {
 	return smtp.NewSMTPSender1(client, s)
 }
 
+// SMTPSenderPool
+func NewSMTPSenderPool(poolSize int, config *SMTPConfig) *SMTPSenderPool {
+	return smtp.NewSMTPSenderPool(poolSize, config)
+}
+func NewSMTPSenderPool1(poolSize int, s *SMTP) *SMTPSenderPool {
+	return smtp.NewSMTPSenderPool1(poolSize, s)
+}
+func NewSMTPSenderPool2(s *SMTP, config PoolConfig) *SMTPSenderPool {
+	return smtp.NewSMTPSenderPool2(s, config)
+}
+
 // Message
 func NewMessage(settings ...MessageSetting) *Message {
 	return smtp.NewMessage(settings...)
 }
+func RawMessage(msg []byte) io.WriterTo {
+	return smtp.RawMessage(msg)
+}
 func SetCharset(charset string) MessageSetting {
 	return smtp.SetCharset(charset)
 }
@@ -74,6 +303,9 @@ func ValidateFormat(email string) (bool, error) {
 func NewVerifier(cfg *Config) *verifier.Verifier {
 	return verifier.NewVerifier(cfg)
 }
+func NewVerifier1(cfg *Config, transport Transport) *verifier.Verifier {
+	return verifier.NewVerifier1(cfg, transport)
+}
 func GenerateSecureCode(length int, charset string) (string, error) {
 	return verifier.GenerateSecureCode(length, charset)
 }
@@ -95,6 +327,44 @@ func SetMode(mode logx.LogLevel) {
 }
 
 const (
+	// SMTP auth
+	SMTPAuthCramMD5         = smtp.SMTPAuthCramMD5
+	SMTPAuthXOAuth2         = smtp.SMTPAuthXOAuth2
+	SMTPAuthLogin           = smtp.SMTPAuthLogin
+	SMTPAuthPlain           = smtp.SMTPAuthPlain
+	SMTPAuthScramSHA1       = smtp.SMTPAuthScramSHA1
+	SMTPAuthScramSHA256     = smtp.SMTPAuthScramSHA256
+	SMTPAuthScramSHA256Plus = smtp.SMTPAuthScramSHA256Plus
+	SMTPAuthOAuthBearer     = smtp.SMTPAuthOAuthBearer
+	// SMTP TLS
+	TLSOpportunistic = smtp.TLSOpportunistic
+	TLSMandatory     = smtp.TLSMandatory
+	NoTLS            = smtp.NoTLS
+	// SMTP IP version
+	IPAny      = smtp.IPAny
+	IPv4Only   = smtp.IPv4Only
+	IPv6Only   = smtp.IPv6Only
+	PreferIPv4 = smtp.PreferIPv4
+	PreferIPv6 = smtp.PreferIPv6
+	// SMTP balance strategy
+	RoundRobin = smtp.RoundRobin
+	Weighted   = smtp.Weighted
+	// SMTP throttle
+	ThrottleBlock = smtp.ThrottleBlock
+	ThrottleFail  = smtp.ThrottleFail
+	// SMTP DSN
+	DSNReturnFull    = smtp.DSNReturnFull
+	DSNReturnHeaders = smtp.DSNReturnHeaders
+	DSNNotifyNever   = smtp.DSNNotifyNever
+	DSNNotifySuccess = smtp.DSNNotifySuccess
+	DSNNotifyFailure = smtp.DSNNotifyFailure
+	DSNNotifyDelay   = smtp.DSNNotifyDelay
+	// queue
+	QueuePending = queue.Pending
+	QueueDead    = queue.Dead
+	// bulk
+	BulkSent   = bulk.Sent
+	BulkFailed = bulk.Failed
 	// verifier
 	Numbers      = verifier.Numbers
 	UpperLetters = verifier.UpperLetters
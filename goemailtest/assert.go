@@ -0,0 +1,77 @@
+package goemailtest
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const defaultCodeLength = 6
+
+// AssertCount 检查 t 已记录 n 封邮件。
+func AssertCount(tb testing.TB, t *Transport, n int) {
+	tb.Helper()
+	if got := t.Len(); got != n {
+		tb.Errorf("goemailtest: %d mails sent, want %d", got, n)
+	}
+}
+
+// AssertLast 检查 t 至少记录了一封邮件，返回最后一封；没有邮件时立即结束测试。
+func AssertLast(tb testing.TB, t *Transport) *Mail {
+	tb.Helper()
+	m := t.Last()
+	if m == nil {
+		tb.Fatal("goemailtest: no mail sent")
+	}
+	return m
+}
+
+// AssertRecipients 检查邮件 m 的信封收件人（包括 Cc、Bcc）与 want 相同，不计顺序。
+func AssertRecipients(tb testing.TB, m *Mail, want ...string) {
+	tb.Helper()
+	got := slices.Sorted(slices.Values(m.Envelope.To))
+	if !slices.Equal(got, slices.Sorted(slices.Values(want))) {
+		tb.Errorf("goemailtest: recipients = %v, want %v", m.Envelope.To, want)
+	}
+}
+
+// AssertSubject 检查邮件 m 的主题为 want。
+func AssertSubject(tb testing.TB, m *Mail, want string) {
+	tb.Helper()
+	if m.Subject != want {
+		tb.Errorf("goemailtest: subject = %q, want %q", m.Subject, want)
+	}
+}
+
+// AssertBodyContains 检查邮件 m 的纯文本或 HTML 正文包含 substr。
+func AssertBodyContains(tb testing.TB, m *Mail, substr string) {
+	tb.Helper()
+	if !strings.Contains(m.Body(), substr) {
+		tb.Errorf("goemailtest: body does not contain %q:\n%s", substr, m.Body())
+	}
+}
+
+// ExtractCode 从邮件 m 的正文中提取第一个由 length 位数字组成的验证码，length <= 0 时为 6 位。
+func ExtractCode(m *Mail, length int) (string, error) {
+	if length <= 0 {
+		length = defaultCodeLength
+	}
+	re := regexp.MustCompile(fmt.Sprintf(`(?:^|\D)(\d{%d})(?:\D|$)`, length))
+	match := re.FindStringSubmatch(m.Body())
+	if match == nil {
+		return "", fmt.Errorf("goemailtest: no %d-digit code in mail body", length)
+	}
+	return match[1], nil
+}
+
+// LastCode 从 t 的最后一封邮件中提取验证码，见 ExtractCode；没有邮件或验证码时立即结束测试。
+func LastCode(tb testing.TB, t *Transport, length int) string {
+	tb.Helper()
+	code, err := ExtractCode(AssertLast(tb, t), length)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return code
+}
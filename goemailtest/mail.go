@@ -0,0 +1,172 @@
+package goemailtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	"github.com/JiuYu77/go-email/smtp"
+)
+
+// Mail 一封已发送的邮件：信封、原始内容，以及解析出的邮件头、正文与附件。
+type Mail struct {
+	// Envelope 发送时的信封，Bcc 收件人只出现在信封中。
+	Envelope smtp.Envelope
+	// Raw 邮件的原始内容。
+	Raw []byte
+
+	// Header 邮件头，值未解码。
+	Header mail.Header
+	// Subject 解码后的主题。
+	Subject string
+	// From、To、Cc 解码后的地址（只含邮箱地址，不含名称）。
+	From string
+	To   []string
+	Cc   []string
+	// Text、HTML 解码后的纯文本与 HTML 正文，多个同类正文按顺序拼接。
+	Text string
+	HTML string
+	// Attachments 附件与内联资源。
+	Attachments []Attachment
+}
+
+// Attachment 邮件中的一个附件或内联资源。
+type Attachment struct {
+	Filename    string
+	ContentType string
+	// Inline 为 true 表示内联资源（Content-Disposition: inline），如 HTML 中引用的图片。
+	Inline bool
+	Data   []byte
+}
+
+// Body 返回纯文本与 HTML 正文，以换行分隔。
+func (m *Mail) Body() string {
+	if m.Text == "" || m.HTML == "" {
+		return m.Text + m.HTML
+	}
+	return m.Text + "\n" + m.HTML
+}
+
+// Attachment 返回文件名为 filename 的附件，没有时返回 nil。
+func (m *Mail) Attachment(filename string) *Attachment {
+	for i := range m.Attachments {
+		if m.Attachments[i].Filename == filename {
+			return &m.Attachments[i]
+		}
+	}
+	return nil
+}
+
+var wordDecoder = mime.WordDecoder{}
+
+// Parse 解析原始邮件 raw（RFC 5322 格式），解码邮件头与 MIME 各部分。
+func Parse(raw []byte) (*Mail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mail{Raw: raw, Header: msg.Header}
+	if m.Subject, err = wordDecoder.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		return nil, fmt.Errorf("goemailtest: decode Subject: %w", err)
+	}
+	if from := addresses(msg.Header, "From"); len(from) > 0 {
+		m.From = from[0]
+	}
+	m.To = addresses(msg.Header, "To")
+	m.Cc = addresses(msg.Header, "Cc")
+
+	if err := m.parsePart(msg.Header, msg.Body); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func addresses(h mail.Header, key string) []string {
+	list, err := h.AddressList(key)
+	if err != nil {
+		return nil
+	}
+	addrs := make([]string, len(list))
+	for i, addr := range list {
+		addrs[i] = addr.Address
+	}
+	return addrs
+}
+
+// header 是 mail.Header 与 multipart 部分的 textproto.MIMEHeader 共同的方法。
+type header interface {
+	Get(key string) string
+}
+
+// parsePart 解析一个 MIME 部分，multipart 部分递归解析其中的每一部分。
+func (m *Mail) parsePart(h header, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.parsePart(p.Header, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decode(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("goemailtest: decode %s part: %w", mediaType, err)
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	filename := dparams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if filename != "" {
+		if decoded, err := wordDecoder.DecodeHeader(filename); err == nil {
+			filename = decoded
+		}
+	}
+
+	switch {
+	case disposition == "attachment" || filename != "" || !strings.HasPrefix(mediaType, "text/"):
+		m.Attachments = append(m.Attachments, Attachment{
+			Filename:    filename,
+			ContentType: mediaType,
+			Inline:      disposition == "inline",
+			Data:        data,
+		})
+	case mediaType == "text/html":
+		m.HTML += string(data)
+	default:
+		m.Text += string(data)
+	}
+	return nil
+}
+
+func decode(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r) // 忽略换行
+	default:
+		return r
+	}
+}
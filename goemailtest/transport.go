@@ -0,0 +1,112 @@
+// Package goemailtest 提供测试发送邮件代码的工具：在内存中记录邮件的 Transport、
+// 将记录的邮件解析为邮件头、正文与附件的 Parse，以及检查收件人、主题、正文与提取验证码的辅助函数。
+//
+//	transport := goemailtest.NewTransport()
+//	v := goemail.NewVerifier1(config, transport)
+//	v.SendVerificationCode("user@example.com", build)
+//	code := goemailtest.LastCode(t, transport, 6)
+package goemailtest
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/JiuYu77/go-email/smtp"
+)
+
+// Transport 在内存中记录邮件的 smtp.Transport，不连接服务器，可以在多个 goroutine 中同时使用。
+type Transport struct {
+	// From 信封发件人为空时使用的发件人，同 SMTP 配置的发件人。
+	From string
+
+	mtx   sync.Mutex
+	err   error
+	mails []*Mail
+}
+
+var _ smtp.Transport = (*Transport)(nil)
+
+// NewTransport 创建一个空的 Transport。
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// SendMessage 实现 smtp.Transport：按邮件头生成信封，记录邮件。
+func (t *Transport) SendMessage(ctx context.Context, m *smtp.Message) error {
+	env, err := m.Envelope()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return err
+	}
+	return t.SendRaw(ctx, env, buf.Bytes())
+}
+
+// SendRaw 实现 smtp.Transport：解析并记录邮件 msg；msg 无法解析时返回错误，不记录。
+func (t *Transport) SendRaw(ctx context.Context, env *smtp.Envelope, msg []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(env.To) == 0 {
+		return smtp.ErrNoRecipients
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	m, err := Parse(slices.Clone(msg))
+	if err != nil {
+		return err
+	}
+	m.Envelope = *env
+	m.Envelope.To = slices.Clone(env.To)
+	if m.Envelope.From == "" {
+		m.Envelope.From = t.From
+	}
+	t.mails = append(t.mails, m)
+	return nil
+}
+
+// Fail 使此后的发送返回 err 且不记录邮件，用于测试发送失败的处理；nil 恢复正常。
+func (t *Transport) Fail(err error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.err = err
+}
+
+// Mails 返回已记录的全部邮件，按发送顺序。
+func (t *Transport) Mails() []*Mail {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return slices.Clone(t.mails)
+}
+
+// Last 返回最后一封邮件，没有邮件时返回 nil。
+func (t *Transport) Last() *Mail {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if len(t.mails) == 0 {
+		return nil
+	}
+	return t.mails[len(t.mails)-1]
+}
+
+// Len 返回已记录的邮件数。
+func (t *Transport) Len() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return len(t.mails)
+}
+
+// Reset 清除已记录的邮件。
+func (t *Transport) Reset() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.mails = nil
+}
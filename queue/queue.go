@@ -0,0 +1,501 @@
+// Package queue 是一个持久化的发件队列：邮件先写入本地目录，再由后台 worker 经
+// SMTPSenderPool 发送，进程重启后继续发送未完成的邮件。
+//
+// 发送失败的邮件按 RetryPolicy 稍后重试；不可重试或用尽重试次数的邮件移入死信（dead）目录，
+// 可通过 List 查看、Retry 重新发送、Purge 删除。
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JiuYu77/go-email/smtp"
+	"github.com/JiuYu77/go-email/utils"
+)
+
+// State 队列中邮件的状态。
+type State string
+
+const (
+	// Pending 等待发送或等待重试。
+	Pending State = "pending"
+	// Dead 已放弃发送的死信。
+	Dead State = "dead"
+)
+
+var (
+	// ErrNotFound 表示队列中没有指定 ID 的邮件。
+	ErrNotFound = errors.New("goemail: queue entry not found")
+	// ErrClosed 表示队列已关闭。
+	ErrClosed = errors.New("goemail: queue is closed")
+)
+
+const defaultPollInterval = time.Second
+
+// Config 队列的设置。
+type Config struct {
+	// Dir 保存邮件的目录，其下的 pending、dead 子目录分别保存待发送的邮件与死信。
+	Dir string
+	// Pool 发送邮件使用的连接池。
+	Pool *smtp.SMTPSenderPool
+	// Workers 同时发送邮件的 worker 数，默认 1。
+	Workers int
+	// RetryPolicy 发送失败后的重试策略：最多尝试次数、等待时间与可重试的错误。
+	// nil 时使用 smtp.NewRetryPolicy(5)，等待时间从 1 秒开始翻倍，最长 5 分钟。
+	RetryPolicy *smtp.RetryPolicy
+	// PollInterval 没有到期的邮件时，worker 检查队列的间隔，默认 1 秒。
+	PollInterval time.Duration
+	// OnDead 邮件移入死信时调用。
+	OnDead func(e *Entry)
+}
+
+// Entry 队列中的一封邮件。
+type Entry struct {
+	ID   string   `json:"id"`
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	// Data 邮件内容（RFC 5322 格式）。
+	Data []byte `json:"data"`
+	// Attempts 已尝试发送的次数。
+	Attempts int `json:"attempts"`
+	// Created 加入队列的时间。
+	Created time.Time `json:"created"`
+	// NextAttempt 下一次尝试发送的时间。
+	NextAttempt time.Time `json:"next_attempt"`
+	// LastError 最近一次发送失败的原因。
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Queue 持久化的发件队列，可以在多个 goroutine 中同时使用。
+type Queue struct {
+	config Config
+	store  *store
+
+	mtx      sync.Mutex
+	pending  map[string]*Entry
+	inflight map[string]bool
+	closed   bool
+
+	wake     chan struct{}
+	stopping chan struct{} // Close 时关闭
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// New 创建队列，读取 config.Dir 中未发送完的邮件，并启动 worker。
+func New(config Config) (*Queue, error) {
+	if config.Pool == nil {
+		return nil, errors.New("goemail: queue: Pool is required")
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.RetryPolicy == nil {
+		config.RetryPolicy = smtp.NewRetryPolicy(5)
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	st, err := newStore(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := st.list(Pending)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		config:   config,
+		store:    st,
+		pending:  make(map[string]*Entry, len(entries)),
+		inflight: make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+		stopping: make(chan struct{}),
+		cancel:   cancel,
+	}
+	for _, e := range entries {
+		q.pending[e.ID] = e
+	}
+	for range config.Workers {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+	return q, nil
+}
+
+// Enqueue 将邮件 m 加入队列，发件人与收件人取自邮件头，返回邮件的 ID。
+func (q *Queue) Enqueue(m *smtp.Message) (string, error) {
+	env, err := m.Envelope()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return "", err
+	}
+	return q.EnqueueRaw(env.From, env.To, buf.Bytes())
+}
+
+// EnqueueRaw 将内容为 data 的邮件加入队列，返回邮件的 ID。
+func (q *Queue) EnqueueRaw(from string, to []string, data []byte) (string, error) {
+	if len(to) == 0 {
+		return "", smtp.ErrNoRecipients
+	}
+	now := time.Now()
+	e := &Entry{
+		ID:          newID(now),
+		From:        from,
+		To:          append([]string(nil), to...),
+		Data:        data,
+		Created:     now,
+		NextAttempt: now,
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if q.closed {
+		return "", ErrClosed
+	}
+	if err := q.store.save(Pending, e); err != nil {
+		return "", err
+	}
+	q.pending[e.ID] = e
+	q.notify()
+	return e.ID, nil
+}
+
+// newID 生成按时间排序的 ID。
+func newID(now time.Time) string {
+	var b [4]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%d-%s", now.UnixNano(), hex.EncodeToString(b[:]))
+}
+
+// notify 唤醒一个等待中的 worker。
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker 循环取出到期的邮件发送，直到队列关闭。
+func (q *Queue) worker(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		e, wait, ok := q.next()
+		if !ok {
+			return
+		}
+		if e != nil {
+			q.deliver(ctx, e)
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-q.stopping:
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// next 取出最早到期且未在发送中的邮件；没有到期的邮件时返回距最近一封到期的时间。
+// 队列已关闭时 ok 为 false。
+func (q *Queue) next() (e *Entry, wait time.Duration, ok bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if q.closed {
+		return nil, 0, false
+	}
+
+	now := time.Now()
+	var due *Entry
+	wait = q.config.PollInterval
+	for id, e := range q.pending {
+		if q.inflight[id] {
+			continue
+		}
+		if d := e.NextAttempt.Sub(now); d > 0 {
+			wait = min(wait, d)
+			continue
+		}
+		if due == nil || e.NextAttempt.Before(due.NextAttempt) {
+			due = e
+		}
+	}
+	if due != nil {
+		q.inflight[due.ID] = true
+		due = clone(due)
+	}
+	return due, wait, true
+}
+
+// deliver 发送一封邮件，并根据结果删除、稍后重试或移入死信。
+func (q *Queue) deliver(ctx context.Context, e *Entry) {
+	rejected, err := q.send(ctx, e)
+	if ctx.Err() != nil {
+		// 队列正在关闭，不计入尝试次数，下次启动时重新发送
+		q.mtx.Lock()
+		delete(q.inflight, e.ID)
+		q.mtx.Unlock()
+		return
+	}
+
+	var dead []*Entry
+	q.mtx.Lock()
+	delete(q.inflight, e.ID)
+	if rejected != nil {
+		if buryErr := q.bury(rejected); buryErr == nil {
+			dead = append(dead, rejected)
+		} else {
+			// 无法保存死信时，被拒绝的收件人留在本邮件中稍后重试，不会丢失
+			if err == nil {
+				e.To = nil
+			}
+			e.To = append(e.To, rejected.To...)
+			err = buryErr
+		}
+	}
+	if _, ok := q.pending[e.ID]; !ok {
+		// 发送期间被 Purge
+	} else if err == nil {
+		if err := q.store.remove(Pending, e.ID); err != nil {
+			utils.Logger.Errorln(utils.LogPrefix, "queue: remove entry:", err)
+		}
+		delete(q.pending, e.ID)
+	} else {
+		e.Attempts++
+		e.LastError = err.Error()
+		if q.config.RetryPolicy.ShouldRetry(e.Attempts, err) {
+			q.retryLater(e)
+		} else if q.bury(e) == nil {
+			if err := q.store.remove(Pending, e.ID); err != nil {
+				utils.Logger.Errorln(utils.LogPrefix, "queue: remove entry:", err)
+			}
+			delete(q.pending, e.ID)
+			dead = append(dead, e)
+		} else {
+			// 死信目录无法写入时同样稍后再试，避免 worker 立即再次取出这封邮件
+			q.retryLater(e)
+		}
+	}
+	q.mtx.Unlock()
+
+	if q.config.OnDead != nil {
+		for _, e := range dead {
+			q.config.OnDead(clone(e))
+		}
+	}
+}
+
+// retryLater 按 RetryPolicy 的等待时间安排下一次发送，调用时须持有 q.mtx。
+func (q *Queue) retryLater(e *Entry) {
+	e.NextAttempt = time.Now().Add(q.config.RetryPolicy.Backoff(e.Attempts))
+	if err := q.store.save(Pending, e); err != nil {
+		utils.Logger.Errorln(utils.LogPrefix, "queue: save entry:", err)
+	}
+	q.pending[e.ID] = e
+}
+
+// bury 将邮件保存到死信目录，调用时须持有 q.mtx。
+func (q *Queue) bury(e *Entry) error {
+	err := q.store.save(Dead, e)
+	if err != nil {
+		utils.Logger.Errorln(utils.LogPrefix, "queue: save dead entry:", err)
+	}
+	return err
+}
+
+// send 从连接池取出连接发送邮件。部分收件人被拒绝时，永久失败的收件人作为 rejected 返回，
+// 以便移入死信；只有暂时失败的收件人留在 e.To 中重试。
+func (q *Queue) send(ctx context.Context, e *Entry) (rejected *Entry, err error) {
+	sender, err := q.config.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer q.config.Pool.Put(sender)
+
+	env := &smtp.Envelope{From: e.From, To: e.To}
+	result, err := sender.SendPartial(ctx, env, smtp.RawMessage(e.Data))
+	if result == nil || (err != nil && (len(result.Accepted) > 0 || len(result.Failed()) == 0)) {
+		// 不是收件人被拒绝导致的失败，整封邮件稍后重试
+		return nil, err
+	}
+
+	if len(result.Accepted) == 0 && len(result.TempFailed) == 0 {
+		// 全部收件人永久失败，整封邮件移入死信
+		return nil, statusError(result.PermFailed[0])
+	}
+	if len(result.PermFailed) > 0 {
+		// 同一封邮件可能多次有收件人被拒绝，每次使用新的 ID，不覆盖之前的死信
+		rejected = clone(e)
+		rejected.ID = newID(time.Now())
+		rejected.To = addresses(result.PermFailed)
+		rejected.Attempts = e.Attempts + 1
+		rejected.LastError = statusError(result.PermFailed[0]).Error()
+	}
+	if len(result.TempFailed) > 0 {
+		e.To = addresses(result.TempFailed)
+		return rejected, statusError(result.TempFailed[0])
+	}
+	return rejected, nil
+}
+
+func addresses(status []smtp.RecipientStatus) []string {
+	to := make([]string, len(status))
+	for i, st := range status {
+		to[i] = st.Address
+	}
+	return to
+}
+
+func statusError(st smtp.RecipientStatus) error {
+	return fmt.Errorf("recipient %s: %w", st.Address, &smtp.SMTPError{
+		Command: "RCPT", Code: st.Code, EnhancedCode: st.EnhancedCode, Message: st.Message,
+	})
+}
+
+func clone(e *Entry) *Entry {
+	c := *e
+	c.To = append([]string(nil), e.To...)
+	return &c
+}
+
+// List 返回状态为 state 的邮件，按加入队列的时间排序。
+func (q *Queue) List(state State) ([]*Entry, error) {
+	var entries []*Entry
+	if state == Pending {
+		q.mtx.Lock()
+		for _, e := range q.pending {
+			entries = append(entries, clone(e))
+		}
+		q.mtx.Unlock()
+	} else {
+		var err error
+		if entries, err = q.store.list(state); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Created.Before(entries[j].Created) })
+	return entries, nil
+}
+
+// Retry 立即重新发送邮件 id：死信移回队列并清零尝试次数；待重试的邮件立即发送。
+func (q *Queue) Retry(id string) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+
+	if e, ok := q.pending[id]; ok {
+		if !q.inflight[id] {
+			e.NextAttempt = time.Now()
+			if err := q.store.save(Pending, e); err != nil {
+				return err
+			}
+		}
+		q.notify()
+		return nil
+	}
+
+	e, err := q.store.load(Dead, id)
+	if err != nil {
+		return err
+	}
+	e.Attempts = 0
+	e.NextAttempt = time.Now()
+	if err := q.store.save(Pending, e); err != nil {
+		return err
+	}
+	if err := q.store.remove(Dead, id); err != nil {
+		return err
+	}
+	q.pending[id] = e
+	q.notify()
+	return nil
+}
+
+// Purge 删除状态为 state 的邮件 ids，不指定 ids 时删除该状态的全部邮件；返回删除的数量。
+// 正在发送的邮件在发送结束后不再重试。
+func (q *Queue) Purge(state State, ids ...string) (int, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if len(ids) == 0 {
+		if state == Pending {
+			for id := range q.pending {
+				ids = append(ids, id)
+			}
+		} else {
+			entries, err := q.store.list(state)
+			if err != nil {
+				return 0, err
+			}
+			for _, e := range entries {
+				ids = append(ids, e.ID)
+			}
+		}
+	}
+
+	n := 0
+	for _, id := range ids {
+		if state == Pending {
+			if _, ok := q.pending[id]; !ok {
+				continue
+			}
+			delete(q.pending, id)
+		}
+		err := q.store.remove(state, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Close 停止 worker 并等待正在发送的邮件结束；ctx 结束时中断正在发送的邮件，
+// 这些邮件留在队列中，下次启动时重新发送。队列不关闭 Config.Pool。
+func (q *Queue) Close(ctx context.Context) error {
+	q.mtx.Lock()
+	if q.closed {
+		q.mtx.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.stopping)
+	q.mtx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/JiuYu77/go-email/utils"
+)
+
+// store 将邮件保存在目录中：每封邮件一个 JSON 文件，按状态存放在 dir/pending、dir/dead 下。
+type store struct {
+	dir string
+}
+
+func newStore(dir string) (*store, error) {
+	if dir == "" {
+		return nil, errors.New("goemail: queue: Dir is required")
+	}
+	for _, state := range []State{Pending, Dead} {
+		if err := os.MkdirAll(filepath.Join(dir, string(state)), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return &store{dir: dir}, nil
+}
+
+func (s *store) path(state State, id string) string {
+	return filepath.Join(s.dir, string(state), id+".json")
+}
+
+// validID 报告 id 能否作为文件名：Retry、Purge 的 id 来自调用者，
+// 不能包含路径分隔符或 ".."，否则可以访问队列目录之外的文件。
+func validID(id string) bool {
+	return filepath.IsLocal(id) && !strings.ContainsAny(id, `/\`)
+}
+
+// list 读取状态为 state 的全部邮件，跳过无法解析的文件。
+func (s *store) list(state State) ([]*Entry, error) {
+	files, err := os.ReadDir(filepath.Join(s.dir, string(state)))
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		e, err := s.load(state, strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				utils.Logger.Errorln(utils.LogPrefix, "queue: load "+name+":", err)
+			}
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *store) load(state State, id string) (*Entry, error) {
+	if !validID(id) {
+		return nil, fmt.Errorf("%w: invalid id %q", ErrNotFound, id)
+	}
+	data, err := os.ReadFile(s.path(state, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := new(Entry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// save 写入临时文件并同步到磁盘后重命名，再同步目录：
+// 进程中断或断电时不会留下不完整的文件，save 返回后邮件已持久保存。
+func (s *store) save(state State, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	name := s.path(state, e.ID)
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// syncDir 将目录项的变更（如重命名）同步到磁盘。Windows 不支持同步目录，忽略。
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *store) remove(state State, id string) error {
+	if !validID(id) {
+		return fmt.Errorf("%w: invalid id %q", ErrNotFound, id)
+	}
+	err := os.Remove(s.path(state, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
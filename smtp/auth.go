@@ -2,20 +2,172 @@ package smtp
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/smtp"
+	"slices"
+	"strings"
+
+	"github.com/JiuYu77/go-email/utils"
 )
 
 type SMTPAuthType = string
 
 const (
-	SMTPAuthCramMD5 SMTPAuthType = "CRAM-MD5"
-	SMTPAuthXOAuth2 SMTPAuthType = "XOAUTH2"
-	SMTPAuthLogin   SMTPAuthType = "LOGIN"
-	SMTPAuthPlain   SMTPAuthType = "PLAIN"
+	SMTPAuthCramMD5         SMTPAuthType = "CRAM-MD5"
+	SMTPAuthXOAuth2         SMTPAuthType = "XOAUTH2"
+	SMTPAuthLogin           SMTPAuthType = "LOGIN"
+	SMTPAuthPlain           SMTPAuthType = "PLAIN"
+	SMTPAuthScramSHA1       SMTPAuthType = "SCRAM-SHA-1"
+	SMTPAuthScramSHA256     SMTPAuthType = "SCRAM-SHA-256"
+	SMTPAuthScramSHA256Plus SMTPAuthType = "SCRAM-SHA-256-PLUS"
+	SMTPAuthOAuthBearer     SMTPAuthType = "OAUTHBEARER"
+)
+
+// 未设置 SMTP.AuthMechanisms 时，按以下顺序选择第一个服务器支持的认证机制。
+// TLS 连接上 PLAIN 的密码受 TLS 保护，优先于安全性较弱的 CRAM-MD5；
+// 非 TLS 连接上则优先使用不发送明文密码的 CRAM-MD5。
+// 服务器不支持 PLAIN 时，XOAUTH2 优先于 LOGIN（此时 password 为访问令牌）。
+var (
+	defaultAuthMechanismsTLS = []SMTPAuthType{
+		SMTPAuthScramSHA256Plus, SMTPAuthScramSHA256, SMTPAuthScramSHA1,
+		SMTPAuthPlain, SMTPAuthXOAuth2, SMTPAuthLogin, SMTPAuthCramMD5,
+	}
+	defaultAuthMechanisms = []SMTPAuthType{
+		SMTPAuthScramSHA256, SMTPAuthScramSHA1,
+		SMTPAuthCramMD5, SMTPAuthPlain, SMTPAuthXOAuth2, SMTPAuthLogin,
+	}
+	// 设置了 SMTP.TokenSource 时只使用 OAuth 2.0 认证机制
+	defaultOAuthMechanisms = []SMTPAuthType{SMTPAuthOAuthBearer, SMTPAuthXOAuth2}
 )
 
+// authSession 由认证过程有状态或需要连接信息的 smtp.Auth 实现（如 SCRAM、OAuth 2.0）。
+// 每个连接都通过 session 得到独立的 smtp.Auth，避免并发连接共享状态；
+// ctx 为建立连接的 ctx，cs 为连接的 TLS 状态，非 TLS 连接为 nil。
+type authSession interface {
+	session(ctx context.Context, cs *tls.ConnectionState) smtp.Auth
+}
+
+// authFor 为一个连接选择认证机制，返回 nil 表示不认证。
+//
+// 优先使用 SetAuth 设置的 smtp.Auth；否则按 AuthMechanisms（为空时按默认顺序）
+// 选择第一个服务器宣告支持的机制。
+func (s *SMTP) authFor(ctx context.Context, client *Client) (smtp.Auth, error) {
+	var cs *tls.ConnectionState
+	if state, ok := client.TLSConnectionState(); ok {
+		cs = &state
+	}
+	if s.auth != nil {
+		if a, ok := s.auth.(authSession); ok {
+			return a.session(ctx, cs), nil
+		}
+		return s.auth, nil
+	}
+	if s.username == "" {
+		return nil, nil
+	}
+
+	ok, ext := client.Extension("AUTH")
+	if !ok {
+		if len(s.AuthMechanisms) > 0 {
+			return nil, fmt.Errorf("%w: the server does not support AUTH", ErrNoAuthMechanism)
+		}
+		return nil, nil
+	}
+	advertised := strings.Fields(strings.ToUpper(ext))
+
+	mechanisms := s.AuthMechanisms
+	switch {
+	case len(mechanisms) > 0:
+	case s.TokenSource != nil:
+		mechanisms = defaultOAuthMechanisms
+	case cs != nil:
+		mechanisms = defaultAuthMechanismsTLS
+	default:
+		mechanisms = defaultAuthMechanisms
+	}
+	for _, m := range mechanisms {
+		m = strings.ToUpper(m)
+		if !slices.Contains(advertised, m) {
+			continue
+		}
+		if m == SMTPAuthScramSHA256Plus {
+			if _, _, err := channelBinding(cs); err != nil {
+				continue
+			}
+		}
+		if auth := s.newAuth(m); auth != nil {
+			utils.Logger.Debugln(utils.LogPrefix, m, "auth")
+			if a, ok := auth.(authSession); ok {
+				return a.session(ctx, cs), nil
+			}
+			return auth, nil
+		}
+	}
+
+	if len(s.AuthMechanisms) > 0 || s.TokenSource != nil {
+		return nil, fmt.Errorf("%w: want one of %v, the server supports %s", ErrNoAuthMechanism, mechanisms, ext)
+	}
+	utils.Logger.Debugln(utils.LogPrefix, "Plain auth")
+	return s.plainAuth(), nil
+}
+
+// newAuth 使用 SMTP 的用户名与密码创建认证机制 m，不支持的机制返回 nil。
+func (s *SMTP) newAuth(m SMTPAuthType) smtp.Auth {
+	switch m {
+	case SMTPAuthPlain:
+		return s.plainAuth()
+	case SMTPAuthLogin:
+		return LoginAuth(s.username, s.password, s.host)
+	case SMTPAuthCramMD5:
+		return smtp.CRAMMD5Auth(s.username, s.password)
+	case SMTPAuthXOAuth2:
+		if s.TokenSource != nil {
+			return XOAuth2Auth1(s.username, s.TokenSource)
+		}
+		return XOAuth2Auth(s.username, s.password)
+	case SMTPAuthOAuthBearer:
+		source := s.TokenSource
+		if source == nil {
+			source = StaticTokenSource(s.password)
+		}
+		return OAuthBearerAuth(s.username, s.host, s.port, source)
+	case SMTPAuthScramSHA1:
+		return ScramSHA1Auth(s.username, s.password)
+	case SMTPAuthScramSHA256:
+		return ScramSHA256Auth(s.username, s.password)
+	case SMTPAuthScramSHA256Plus:
+		return ScramSHA256PlusAuth(s.username, s.password)
+	}
+	return nil
+}
+
+// plainAuth 返回 PLAIN 认证机制。net/smtp 的 PlainAuth 拒绝在未加密的连接上认证（localhost 除外），
+// 设置 AllowInsecureAuth 时改用不做此检查的实现。
+func (s *SMTP) plainAuth() smtp.Auth {
+	if s.AllowInsecureAuth {
+		return &insecurePlainAuth{username: s.username, password: s.password, host: s.host}
+	}
+	return smtp.PlainAuth("", s.username, s.password, s.host)
+}
+
+// LoginAuth 返回 LOGIN 认证机制，host 为 SMTP 服务器主机名。
+func LoginAuth(username, password, host string) smtp.Auth {
+	return &loginAuth{username: username, password: password, host: host}
+}
+
+// XOAuth2Auth 返回 XOAUTH2 认证机制，token 为 OAuth 2.0 访问令牌。
+func XOAuth2Auth(username, token string) smtp.Auth {
+	return &xoauth2Auth{username: username, source: StaticTokenSource(token)}
+}
+
+// XOAuth2Auth1 返回 XOAUTH2 认证机制，每次认证都从 source 获取访问令牌。
+func XOAuth2Auth1(username string, source TokenSource) smtp.Auth {
+	return &xoauth2Auth{username: username, source: source}
+}
+
 // loginAuth is an smtp.Auth that implements the LOGIN authentication mechanism.
 type loginAuth struct {
 	username string
@@ -57,23 +209,54 @@ func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
 	}
 }
 
+// insecurePlainAuth is an smtp.Auth that implements the PLAIN authentication mechanism
+// without requiring TLS.
+type insecurePlainAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *insecurePlainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if server.Name != a.host {
+		return "", nil, errors.New("gomail: wrong host name")
+	}
+	return SMTPAuthPlain, []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+func (a *insecurePlainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("gomail: unexpected server challenge")
+	}
+	return nil, nil
+}
+
 // xoauth2Auth is an smtp.Auth that implements the XOAUTH2 authentication mechanism.
 // xoauth2Auth 是 OAuth2.0 认证机制的实现.
 type xoauth2Auth struct {
 	username string
-	token    string
+	source   TokenSource
+	ctx      context.Context // 获取令牌使用的 ctx，nil 表示 context.Background()
+}
+
+func (a *xoauth2Auth) session(ctx context.Context, _ *tls.ConnectionState) smtp.Auth {
+	return &xoauth2Auth{username: a.username, source: a.source, ctx: ctx}
 }
 
 func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (proto string, toServer []byte, err error) {
-	proto = "XOAUTH2"
-	toServer = []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01")
-	err = nil
+	token, err := sourceToken(a.ctx, a.source)
+	if err != nil {
+		return "", nil, err
+	}
+	proto = SMTPAuthXOAuth2
+	toServer = []byte("user=" + a.username + "\x01auth=Bearer " + token + "\x01\x01")
 	return
 }
 func (a *xoauth2Auth) Next(fromServer []byte, more bool) (toServer []byte, err error) {
 	if more {
-		// 处理服务器挑战（通常OAuth2不需要）
-		return []byte(""), fmt.Errorf("unexpected server challenge")
+		// 令牌被拒绝时，服务器以 JSON 格式的错误作为挑战，客户端回复空行后，服务器返回错误响应
+		handleOAuthError(SMTPAuthXOAuth2, a.source, fromServer)
+		return []byte{}, nil
 	}
 	return nil, nil
 }
@@ -0,0 +1,338 @@
+package smtp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/JiuYu77/go-email/utils"
+)
+
+// BalanceStrategy 选择发送账号的策略。
+type BalanceStrategy int
+
+const (
+	// RoundRobin 依次轮流使用各账号。默认值。
+	RoundRobin BalanceStrategy = iota
+	// Weighted 按 Account.Weight 的比例分配（平滑加权轮询）。
+	Weighted
+)
+
+// Account 负载均衡中的一个发送账号。
+type Account struct {
+	// Name 账号名称，用于状态文件与 Usage，不能重复；为空时使用 "用户名@主机:端口"。
+	Name string
+	// SMTP 发送使用的 SMTP。
+	SMTP *SMTP
+	// Weight 权重，Weighted 策略下使用，默认 1。
+	Weight int
+	// DailyQuota 每天（本地时间）最多发送的邮件数，0 表示不限制。
+	DailyQuota int
+	// HourlyQuota 每小时最多发送的邮件数，0 表示不限制。
+	HourlyQuota int
+}
+
+// NewAccount 使用 config 创建一个权重为 1 的账号。
+func NewAccount(config *SMTPConfig, dailyQuota, hourlyQuota int) *Account {
+	return &Account{
+		SMTP:        NewSMTP(config.Host, config.Port, config.Username, config.Password, config.From),
+		Weight:      1,
+		DailyQuota:  dailyQuota,
+		HourlyQuota: hourlyQuota,
+	}
+}
+
+func (a *Account) name() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.SMTP.username + "@" + addr(a.SMTP.host, a.SMTP.port)
+}
+
+// AccountUsage 账号在当前日、当前小时内已发送的邮件数。
+type AccountUsage struct {
+	Name        string `json:"name"`
+	Day         string `json:"day"`  // 如 "2006-01-02"
+	Hour        string `json:"hour"` // 如 "2006-01-02T15"
+	DailySent   int    `json:"daily_sent"`
+	HourlySent  int    `json:"hourly_sent"`
+	DailyQuota  int    `json:"daily_quota"`
+	HourlyQuota int    `json:"hourly_quota"`
+}
+
+// Balancer 在多个账号之间分配邮件，如多个 QQ、163、Gmail 账号。
+//
+// 每个账号的发送数按 DailyQuota、HourlyQuota 计数，用尽的账号自动跳过，
+// 下一天（小时）重新计数。StateFile 不为空时，计数保存在该文件中，重启后继续使用。
+// 某个账号连接失败、认证失败、连接中断或返回暂时性错误（4xx）时，改用下一个账号发送；
+// 其他错误，如永久性错误（5xx）、附件读取失败，与账号无关，直接返回。
+//
+// Balancer 可以在多个 goroutine 中同时使用。
+type Balancer struct {
+	// Strategy 选择账号的策略，默认 RoundRobin。
+	Strategy BalanceStrategy
+
+	stateFile string
+	accounts  []*Account
+	counters  map[string]*counter
+
+	mtx     sync.Mutex
+	next    int   // RoundRobin 下一个账号
+	current []int // Weighted 的当前权重
+
+	saveMtx sync.Mutex // 串行写入状态文件，避免旧的计数覆盖新的计数
+}
+
+// counter 一个账号的发送计数。
+type counter struct {
+	Day        string `json:"day"`
+	DailySent  int    `json:"daily_sent"`
+	Hour       string `json:"hour"`
+	HourlySent int    `json:"hourly_sent"`
+}
+
+// reset 进入新的一天（小时）时清零计数。
+func (c *counter) reset(now time.Time) {
+	if day := now.Format(time.DateOnly); c.Day != day {
+		c.Day, c.DailySent = day, 0
+	}
+	if hour := now.Format("2006-01-02T15"); c.Hour != hour {
+		c.Hour, c.HourlySent = hour, 0
+	}
+}
+
+// NewBalancer 创建 Balancer，stateFile 为保存发送计数的 JSON 文件，为空时只在内存中计数。
+// 文件存在时读取其中的计数。计数按账号名称保存，账号名称重复时返回错误。
+func NewBalancer(stateFile string, accounts ...*Account) (*Balancer, error) {
+	names := make(map[string]bool, len(accounts))
+	for _, a := range accounts {
+		name := a.name()
+		if names[name] {
+			return nil, fmt.Errorf("goemail: duplicate balancer account name %q", name)
+		}
+		names[name] = true
+	}
+
+	b := &Balancer{
+		stateFile: stateFile,
+		accounts:  accounts,
+		counters:  make(map[string]*counter, len(accounts)),
+		current:   make([]int, len(accounts)),
+	}
+	if stateFile != "" {
+		data, err := os.ReadFile(stateFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &b.counters); err != nil {
+				return nil, fmt.Errorf("goemail: invalid balancer state file %s: %w", stateFile, err)
+			}
+		}
+	}
+	for _, a := range accounts {
+		name := a.name()
+		if b.counters[name] == nil {
+			b.counters[name] = &counter{}
+		}
+	}
+	return b, nil
+}
+
+// Send 使用一个有剩余配额的账号发送邮件 m，返回实际使用的账号名称。
+//
+// Args
+//   - whereFrom: true 使用账号配置中的发件人，false 从消息中获取发件人。
+//   - m: 邮件内容
+func (b *Balancer) Send(whereFrom bool, m *Message) (string, error) {
+	return b.SendContext(context.Background(), whereFrom, m)
+}
+
+// SendContext 与 Send 相同，ctx 作用于连接与发送的全过程。
+func (b *Balancer) SendContext(ctx context.Context, whereFrom bool, m *Message) (string, error) {
+	return b.send(ctx, func(s *SMTP) (*Envelope, error) {
+		return s.envelope(whereFrom, m)
+	}, m)
+}
+
+// SendEnvelope 按信封 env 发送邮件 msg，env.From 为空时使用账号配置中的发件人。
+// 返回实际使用的账号名称。
+func (b *Balancer) SendEnvelope(ctx context.Context, env *Envelope, msg io.WriterTo) (string, error) {
+	return b.send(ctx, func(s *SMTP) (*Envelope, error) {
+		if env.From != "" {
+			return env, nil
+		}
+		e := *env
+		e.From = s.from
+		return &e, nil
+	}, msg)
+}
+
+// SendMessage 实现 Transport，同 SendContext(ctx, false, m)。
+func (b *Balancer) SendMessage(ctx context.Context, m *Message) error {
+	_, err := b.SendContext(ctx, false, m)
+	return err
+}
+
+// SendRaw 实现 Transport，同 SendEnvelope。
+func (b *Balancer) SendRaw(ctx context.Context, env *Envelope, msg []byte) error {
+	_, err := b.SendEnvelope(ctx, env, rawMessage(msg))
+	return err
+}
+
+func (b *Balancer) send(ctx context.Context, envelope func(*SMTP) (*Envelope, error), msg io.WriterTo) (string, error) {
+	tried := make([]bool, len(b.accounts))
+	var errs []error
+	for {
+		i := b.acquire(tried)
+		if i < 0 {
+			if len(errs) == 0 {
+				return "", ErrQuotaExhausted
+			}
+			return "", fmt.Errorf("%w: %w", ErrNoRelayAvailable, errors.Join(errs...))
+		}
+		tried[i] = true
+		a := b.accounts[i]
+
+		env, err := envelope(a.SMTP)
+		dialed := true
+		if err == nil {
+			dialed, err = dialAndSendEnvelope(ctx, a.SMTP, env, msg)
+		}
+		if err == nil {
+			b.save()
+			return a.name(), nil
+		}
+
+		b.release(i)
+		if ctx.Err() != nil || !shouldFailover(err, dialed) {
+			return a.name(), err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", a.name(), err))
+		utils.Logger.Debugf(utils.LogPrefix+"account %s failed, try next account: %v", a.name(), err)
+	}
+}
+
+// acquire 按策略选择一个未尝试过、有剩余配额的账号，并预先占用一个配额；没有可用账号时返回 -1。
+func (b *Balancer) acquire(tried []bool) int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	available := func(i int) bool {
+		if tried[i] {
+			return false
+		}
+		a := b.accounts[i]
+		c := b.counters[a.name()]
+		c.reset(now)
+		return (a.DailyQuota <= 0 || c.DailySent < a.DailyQuota) &&
+			(a.HourlyQuota <= 0 || c.HourlySent < a.HourlyQuota)
+	}
+
+	i := -1
+	switch b.Strategy {
+	case Weighted:
+		// 平滑加权轮询：每个可用账号的当前权重加上其权重，选择当前权重最大者，并减去总权重
+		total := 0
+		for k, a := range b.accounts {
+			if !available(k) {
+				continue
+			}
+			w := max(a.Weight, 1)
+			b.current[k] += w
+			total += w
+			if i < 0 || b.current[k] > b.current[i] {
+				i = k
+			}
+		}
+		if i >= 0 {
+			b.current[i] -= total
+		}
+	default:
+		n := len(b.accounts)
+		for k := range n {
+			if j := (b.next + k) % n; available(j) {
+				i = j
+				b.next = j + 1
+				break
+			}
+		}
+	}
+
+	if i >= 0 {
+		c := b.counters[b.accounts[i].name()]
+		c.DailySent++
+		c.HourlySent++
+	}
+	return i
+}
+
+// release 发送失败时归还 acquire 占用的配额。
+func (b *Balancer) release(i int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	c := b.counters[b.accounts[i].name()]
+	c.DailySent = max(c.DailySent-1, 0)
+	c.HourlySent = max(c.HourlySent-1, 0)
+}
+
+// save 将计数写入状态文件：先写入临时文件，再重命名，避免写入中断时损坏原文件。
+// 同时只有一个 save 在读取并写入计数，后写入的总是较新的计数。
+func (b *Balancer) save() {
+	if b.stateFile == "" {
+		return
+	}
+	b.saveMtx.Lock()
+	defer b.saveMtx.Unlock()
+	b.mtx.Lock()
+	data, err := json.MarshalIndent(b.counters, "", "  ")
+	b.mtx.Unlock()
+	if err == nil {
+		err = writeFileAtomic(b.stateFile, data)
+	}
+	if err != nil {
+		utils.Logger.Errorln(utils.LogPrefix, "save balancer state:", err)
+	}
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// Usage 返回每个账号当前的发送计数，顺序与创建时相同。
+func (b *Balancer) Usage() []AccountUsage {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	now := time.Now()
+	usage := make([]AccountUsage, len(b.accounts))
+	for i, a := range b.accounts {
+		c := b.counters[a.name()]
+		c.reset(now)
+		usage[i] = AccountUsage{
+			Name: a.name(), Day: c.Day, Hour: c.Hour,
+			DailySent: c.DailySent, HourlySent: c.HourlySent,
+			DailyQuota: a.DailyQuota, HourlyQuota: a.HourlyQuota,
+		}
+	}
+	return usage
+}
@@ -0,0 +1,215 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/JiuYu77/go-email/utils"
+)
+
+// Resolver 解析收件人域名的邮件服务器，*net.Resolver 即实现了此接口。
+// 测试时可替换为返回固定记录的实现。
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DirectSender 不经过中继服务器，直接把邮件投递到收件人域名的邮件服务器（MX），
+// 适用于系统通知等不需要账号的场景。
+//
+// 收件人按域名分组，每个域名依次尝试 MX 记录中的服务器（按优先级，相同优先级的随机排列），端口 25，
+// 服务器支持时使用 STARTTLS（不校验证书，RFC 7435 的机会性加密）。
+// 没有 MX 记录时使用域名本身的 A/AAAA 记录；域名声明不接收邮件（null MX，RFC 7505）时返回 ErrNullMX。
+type DirectSender struct {
+	// LocalName EHLO 命令发送的主机名，应为发送方 IP 反向解析（PTR）的域名，
+	// 否则很多服务器会拒收。
+	LocalName string
+	// From 默认的信封发件人，只在 SendEnvelope 的 env.From 为空时使用。
+	From string
+	// Resolver 解析 MX 与 A/AAAA 记录，nil 时使用 net.DefaultResolver。
+	Resolver Resolver
+	// Configure 在连接每个服务器前调用，可修改 SMTP 的设置，如超时时间、TLSPolicy、
+	// SourceAddrs、Dialer 等。
+	Configure func(s *SMTP)
+}
+
+// DomainResult 一个收件人域名的投递结果。
+type DomainResult struct {
+	Domain string `json:"domain"`
+	// Host 最后尝试的服务器，投递成功时为接收邮件的服务器。
+	Host string `json:"host"`
+	// Result 每个收件人的结果，未能与服务器完成邮件事务时为 nil。
+	Result *SendResult `json:"result,omitempty"`
+	// Err 该域名投递失败的原因，nil 表示所有收件人都被接收。
+	Err error `json:"-"`
+}
+
+// NewDirectSender 创建一个 DirectSender，localName 为 EHLO 命令发送的主机名。
+func NewDirectSender(localName string) *DirectSender {
+	return &DirectSender{LocalName: localName}
+}
+
+// Send 将邮件 m 投递给 To、Cc、Bcc 中的所有收件人，返回每个域名的结果。
+// 有域名投递失败时，error 为各域名错误的组合。
+func (d *DirectSender) Send(ctx context.Context, m *Message) ([]DomainResult, error) {
+	env, err := m.Envelope()
+	if err != nil {
+		return nil, err
+	}
+	return d.SendEnvelope(ctx, env, m)
+}
+
+// SendMessage 实现 Transport，同 Send。
+func (d *DirectSender) SendMessage(ctx context.Context, m *Message) error {
+	_, err := d.Send(ctx, m)
+	return err
+}
+
+// SendRaw 实现 Transport，同 SendEnvelope。
+func (d *DirectSender) SendRaw(ctx context.Context, env *Envelope, msg []byte) error {
+	_, err := d.SendEnvelope(ctx, env, rawMessage(msg))
+	return err
+}
+
+// SendEnvelope 按信封 env 投递邮件 msg，返回每个域名的结果，顺序与域名在 env.To 中首次出现的顺序相同。
+// env.From 为空时使用 DirectSender.From。
+func (d *DirectSender) SendEnvelope(ctx context.Context, env *Envelope, msg io.WriterTo) ([]DomainResult, error) {
+	from := env.From
+	if from == "" {
+		from = d.From
+	}
+	if len(env.To) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	var (
+		domains []string
+		groups  = make(map[string][]string)
+	)
+	for _, addr := range env.To {
+		at := strings.LastIndexByte(addr, '@')
+		if at < 0 {
+			return nil, fmt.Errorf("goemail: invalid recipient %q", addr)
+		}
+		domain := strings.ToLower(addr[at+1:])
+		if _, ok := groups[domain]; !ok {
+			domains = append(domains, domain)
+		}
+		groups[domain] = append(groups[domain], addr)
+	}
+
+	results := make([]DomainResult, 0, len(domains))
+	var errs []error
+	for _, domain := range domains {
+		sub := &Envelope{From: from, To: groups[domain], DSN: env.DSN}
+		r := d.deliver(ctx, domain, sub, msg)
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", domain, r.Err))
+		}
+		results = append(results, r)
+	}
+	return results, errors.Join(errs...)
+}
+
+// deliver 依次尝试域名的邮件服务器，直到投递成功或遇到永久性错误；
+// 连接失败或暂时性错误（4xx）时尝试下一个服务器。
+func (d *DirectSender) deliver(ctx context.Context, domain string, env *Envelope, msg io.WriterTo) DomainResult {
+	r := DomainResult{Domain: domain}
+	hosts, err := d.lookup(ctx, domain)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	for _, host := range hosts {
+		r.Host = host
+		r.Result, r.Err = d.sendTo(ctx, host, env, msg)
+		// 已有收件人被接收时不再尝试其他服务器，避免重复投递
+		if r.Err == nil || IsPermanent(r.Err) || ctx.Err() != nil ||
+			(r.Result != nil && len(r.Result.Accepted) > 0) {
+			break
+		}
+		utils.Logger.Debugf(utils.LogPrefix+"deliver to %s failed, try next MX: %v", host, r.Err)
+	}
+	return r
+}
+
+// sendTo 连接邮件服务器 host 并发送邮件。
+func (d *DirectSender) sendTo(ctx context.Context, host string, env *Envelope, msg io.WriterTo) (*SendResult, error) {
+	s := NewSMTP(host, 25, "", "", env.From)
+	s.LocalName = d.LocalName
+	s.SSL = false
+	// 机会性加密：MX 服务器的证书通常与主机名不符，不校验证书
+	s.TLSConfig = &tls.Config{ServerName: host, InsecureSkipVerify: true}
+	if d.Configure != nil {
+		d.Configure(s)
+	}
+
+	sender, err := s.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sender.Quit()
+
+	result, err := sender.SendPartial(ctx, env, msg)
+	if err == nil && len(result.Failed()) > 0 {
+		// 部分收件人被拒绝：返回第一个失败收件人的状态
+		st := result.Failed()[0]
+		err = fmt.Errorf("goemail: recipient %s: %w", st.Address, &SMTPError{
+			Command: "RCPT", Code: st.Code, EnhancedCode: st.EnhancedCode, Message: st.Message,
+		})
+	}
+	return result, err
+}
+
+// lookup 返回域名的邮件服务器，按优先级排列。
+func (d *DirectSender) lookup(ctx context.Context, domain string) ([]string, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	name := domainToASCII(domain)
+
+	mxs, err := resolver.LookupMX(ctx, name)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if len(mxs) == 0 {
+		// 没有 MX 记录，以域名本身作为邮件服务器（RFC 5321 第 5.1 节）
+		if _, err := resolver.LookupHost(ctx, name); err != nil {
+			if isNotFound(err) {
+				return nil, fmt.Errorf("%w: %s", ErrNoMailHost, domain)
+			}
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+
+	if len(mxs) == 1 && (mxs[0].Host == "." || mxs[0].Host == "") {
+		return nil, fmt.Errorf("%w: %s", ErrNullMX, domain)
+	}
+	// 优先级相同的服务器随机排列，分散负载（RFC 5321 第 5.1 节）
+	mxs = slices.Clone(mxs)
+	rand.Shuffle(len(mxs), func(i, j int) { mxs[i], mxs[j] = mxs[j], mxs[i] })
+	slices.SortStableFunc(mxs, func(a, b *net.MX) int { return int(a.Pref) - int(b.Pref) })
+	hosts := make([]string, 0, len(mxs))
+	for _, mx := range mxs {
+		if host := strings.TrimSuffix(mx.Host, "."); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+// isNotFound 报告 DNS 查询的错误是否表示记录不存在。
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,235 @@
+package smtp
+
+import (
+	"context"
+	"sync"
+)
+
+// DispatcherConfig Dispatcher 的设置。
+type DispatcherConfig struct {
+	// Workers 同时发送邮件的 worker 数，默认与连接池的 MaxSize 相同。
+	Workers int
+	// BufferSize 等待发送的邮件数上限，默认与 Workers 相同。
+	// 缓冲区已满时 Submit 阻塞等待（背压），TrySubmit 返回 ErrDispatcherFull。
+	BufferSize int
+	// WhereFrom 为 true 使用 SMTP 中的发件人，false 从邮件中获取发件人，同 SMTPSender.Send。
+	WhereFrom bool
+	// RetryPolicy 发送失败后的重试策略，nil 时使用连接池的 SMTP.RetryPolicy。
+	RetryPolicy *RetryPolicy
+}
+
+// Dispatcher 异步发送邮件：Submit 将邮件放入缓冲区后立即返回 Future，
+// 由后台 worker 经 SMTPSenderPool 发送，调用者通过 Future 等待或接收发送结果。
+// Dispatcher 可以在多个 goroutine 中同时使用。
+type Dispatcher struct {
+	pool   *SMTPSenderPool
+	config DispatcherConfig
+
+	mtx     sync.RWMutex // 保护 closed
+	closed  bool
+	closing chan struct{}  // Shutdown 时关闭，唤醒等待缓冲区的 Submit
+	submits sync.WaitGroup // 正在等待缓冲区的 Submit，全部返回后才能关闭 jobs
+	jobs    chan *Future
+
+	ctx    context.Context // Shutdown 超时后取消，中断正在发送的邮件
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Future 一封已提交邮件的发送结果，发送结束前 Done 返回的 channel 不会关闭。
+type Future struct {
+	// Message 提交的邮件。
+	Message *Message
+
+	done   chan struct{}
+	result *SendResult
+	err    error
+
+	mtx       sync.Mutex
+	callbacks []func(*SendResult, error)
+}
+
+// NewDispatcher 创建 Dispatcher 并启动 worker，使用完毕后调用 Shutdown。
+// Dispatcher 不关闭 pool。
+func NewDispatcher(pool *SMTPSenderPool, config DispatcherConfig) *Dispatcher {
+	if config.Workers <= 0 {
+		config.Workers = pool.config.MaxSize
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = config.Workers
+	}
+	if config.RetryPolicy == nil {
+		config.RetryPolicy = pool.smtp.RetryPolicy
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		pool:    pool,
+		config:  config,
+		closing: make(chan struct{}),
+		jobs:    make(chan *Future, config.BufferSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	for range config.Workers {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	return d
+}
+
+// Submit 提交邮件 m，返回 m 的 Future。缓冲区已满时等待，直到有空位或 ctx 结束；
+// 等待期间调用 Shutdown 时返回 ErrDispatcherClosed。
+// ctx 只用于等待缓冲区，不影响提交之后的发送。
+func (d *Dispatcher) Submit(ctx context.Context, m *Message) (*Future, error) {
+	d.mtx.RLock()
+	if d.closed {
+		d.mtx.RUnlock()
+		return nil, ErrDispatcherClosed
+	}
+	d.submits.Add(1)
+	d.mtx.RUnlock()
+	defer d.submits.Done()
+
+	f := newFuture(m)
+	select {
+	case d.jobs <- f:
+		return f, nil
+	case <-d.closing:
+		return nil, ErrDispatcherClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TrySubmit 与 Submit 相同，但缓冲区已满时不等待，立即返回 ErrDispatcherFull。
+func (d *Dispatcher) TrySubmit(m *Message) (*Future, error) {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	if d.closed {
+		return nil, ErrDispatcherClosed
+	}
+
+	f := newFuture(m)
+	select {
+	case d.jobs <- f:
+		return f, nil
+	default:
+		return nil, ErrDispatcherFull
+	}
+}
+
+// Shutdown 停止接受新邮件，等待已提交的邮件全部发送完毕。
+// ctx 结束时中断正在发送与尚未发送的邮件（它们的 Future 返回 context.Canceled），
+// 等待 worker 退出后返回 ctx 的错误。
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mtx.Lock()
+	first := !d.closed
+	if first {
+		d.closed = true
+		close(d.closing)
+	}
+	d.mtx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		if first {
+			// 等待中的 Submit 已被 closing 唤醒，它们返回后不会再写入 jobs
+			d.submits.Wait()
+			close(d.jobs)
+		}
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for f := range d.jobs {
+		f.complete(d.send(f.Message))
+	}
+}
+
+// send 从连接池取出连接发送邮件 m，按 RetryPolicy 重试。
+func (d *Dispatcher) send(m *Message) (*SendResult, error) {
+	var result *SendResult
+	err := d.config.RetryPolicy.Do(d.ctx, func(ctx context.Context) error {
+		sender, err := d.pool.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		defer d.pool.Put(sender)
+
+		env, err := sender.envelope(d.config.WhereFrom, m)
+		if err != nil {
+			return err
+		}
+		result, err = sender.SendPartial(ctx, env, m)
+		return err
+	})
+	return result, err
+}
+
+/* ####################################################################### */
+
+func newFuture(m *Message) *Future {
+	return &Future{Message: m, done: make(chan struct{})}
+}
+
+// Done 返回一个 channel，发送结束（成功或失败）后关闭。
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待发送结束，返回每个收件人的结果与错误；ctx 结束时返回 ctx 的错误，不影响发送。
+// 发送失败时 SendResult 可能为 nil，部分收件人被拒绝时 SendResult 中记录了它们的响应。
+func (f *Future) Wait(ctx context.Context) (*SendResult, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Result 等待发送结束，返回结果与错误。
+func (f *Future) Result() (*SendResult, error) {
+	<-f.done
+	return f.result, f.err
+}
+
+// OnDone 注册发送结束后的回调，回调在 worker goroutine 中调用；
+// 注册时已经发送结束则立即在当前 goroutine 中调用。
+func (f *Future) OnDone(fn func(result *SendResult, err error)) {
+	f.mtx.Lock()
+	select {
+	case <-f.done:
+		f.mtx.Unlock()
+		fn(f.result, f.err)
+	default:
+		f.callbacks = append(f.callbacks, fn)
+		f.mtx.Unlock()
+	}
+}
+
+func (f *Future) complete(result *SendResult, err error) {
+	f.mtx.Lock()
+	f.result, f.err = result, err
+	close(f.done)
+	callbacks := f.callbacks
+	f.callbacks = nil
+	f.mtx.Unlock()
+
+	for _, fn := range callbacks {
+		fn(result, err)
+	}
+}
@@ -0,0 +1,231 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// 国际化电子邮件地址（Email Address Internationalization, EAI），RFC 6531、RFC 6532。
+
+// ErrSMTPUTF8Unsupported 表示地址的本地部分（@ 之前）含有非 ASCII 字符，
+// 而服务器不支持 SMTPUTF8 扩展，无法投递。
+var ErrSMTPUTF8Unsupported = errors.New("goemail: server does not support SMTPUTF8")
+
+// addressHeaders 是内容为邮箱地址列表的头信息字段。
+var addressHeaders = map[string]bool{
+	"From":     true,
+	"Sender":   true,
+	"To":       true,
+	"Cc":       true,
+	"Bcc":      true,
+	"Reply-To": true,
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// hasUTF8Address 报告邮件的地址头信息中是否含有国际化邮箱地址。
+// 显示名称在设置时已按 RFC 2047 编码，因此这里出现的非 ASCII 字符只可能来自地址本身。
+func (m *Message) hasUTF8Address() bool {
+	for k, values := range m.header {
+		if !addressHeaders[k] {
+			continue
+		}
+		for _, v := range values {
+			if !isASCII(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// needsSMTPUTF8 报告发送这封邮件是否需要 SMTPUTF8 扩展：信封地址或邮件头中含有 UTF-8 地址。
+func needsSMTPUTF8(from string, to []string, msg any) bool {
+	if !isASCII(from) {
+		return true
+	}
+	for _, addr := range to {
+		if !isASCII(addr) {
+			return true
+		}
+	}
+
+	switch m := msg.(type) {
+	case *Message:
+		return m.hasUTF8Address()
+	case rawMessage:
+		return !isASCII(m.header())
+	}
+	return false
+}
+
+// asciiAddress 将邮箱地址的域名部分转换为 IDNA（punycode）形式。
+// 本地部分含有非 ASCII 字符时无法转换，返回 ErrSMTPUTF8Unsupported。
+func asciiAddress(addr string) (string, error) {
+	if isASCII(addr) {
+		return addr, nil
+	}
+	at := strings.LastIndexByte(addr, '@')
+	if at < 0 || !isASCII(addr[:at]) {
+		return "", fmt.Errorf("%w: cannot deliver to %q, its local part is not ASCII", ErrSMTPUTF8Unsupported, addr)
+	}
+	return addr[:at+1] + domainToASCII(addr[at+1:]), nil
+}
+
+// asciiAddressList 转换地址头信息中的每一个地址，无法解析的值原样返回。
+func asciiAddressList(value string) string {
+	list, err := mail.ParseAddressList(value)
+	if err != nil {
+		return value
+	}
+
+	out := make([]string, 0, len(list))
+	for _, a := range list {
+		addr, err := asciiAddress(a.Address)
+		if err != nil {
+			return value
+		}
+		a.Address = addr
+		out = append(out, a.String())
+	}
+	return strings.Join(out, ", ")
+}
+
+// utf8AddressList 将地址头信息中编码过的显示名称解码为 UTF-8，无法解析的值原样返回。
+func utf8AddressList(value string) string {
+	list, err := mail.ParseAddressList(value)
+	if err != nil {
+		return value
+	}
+
+	out := make([]string, 0, len(list))
+	for _, a := range list {
+		if a.Name == "" {
+			out = append(out, a.Address)
+			continue
+		}
+		name := a.Name
+		if hasSpecials(name) {
+			name = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+		}
+		out = append(out, name+" <"+a.Address+">")
+	}
+	return strings.Join(out, ", ")
+}
+
+// domainToASCII 将国际化域名转换为 IDNA 的 ASCII 形式（A-label），如 "例子.中国" -> "xn--fsqu00a.xn--fiqs8s"。
+//
+// 只做小写转换与全角句号的映射，不做完整的 UTS #46 映射。
+func domainToASCII(domain string) string {
+	domain = strings.Map(func(r rune) rune {
+		switch r {
+		case '。', '．', '｡':
+			return '.'
+		}
+		return r
+	}, strings.ToLower(domain))
+
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if !isASCII(label) {
+			labels[i] = "xn--" + punycode(label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// Punycode 参数，RFC 3492 第 5 节。
+const (
+	pcBase        = 36
+	pcTMin        = 1
+	pcTMax        = 26
+	pcSkew        = 38
+	pcDamp        = 700
+	pcInitialBias = 72
+	pcInitialN    = 128
+)
+
+// punycode 按 RFC 3492 编码一个域名标签（不含 "xn--" 前缀）。
+func punycode(label string) string {
+	runes := []rune(label)
+	out := make([]byte, 0, len(label))
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	b := len(out)
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := rune(pcInitialN), 0, pcInitialBias
+	for h < len(runes) {
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := pcBase; ; k += pcBase {
+				t := min(max(k-bias, pcTMin), pcTMax)
+				if q < t {
+					break
+				}
+				out = append(out, punycodeDigit(t+(q-t)%(pcBase-t)))
+				q = (q - t) / (pcBase - t)
+			}
+			out = append(out, punycodeDigit(q))
+			bias = punycodeAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out)
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punycodeAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= pcDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((pcBase-pcTMin)*pcTMax)/2 {
+		delta /= pcBase - pcTMin
+		k += pcBase
+	}
+	return k + (pcBase-pcTMin+1)*delta/(delta+pcSkew)
+}
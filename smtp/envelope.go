@@ -0,0 +1,135 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Envelope 邮件的信封，即 SMTP 事务中 MAIL、RCPT 命令使用的发件人与收件人，
+// 可以与邮件头中的 From、To 不同。
+type Envelope struct {
+	From string   // 发件人，MAIL FROM
+	To   []string // 收件人，RCPT TO
+	// DSN 本次发送的投递状态通知参数，nil 时使用 SMTP.DSN。
+	DSN *DSN
+}
+
+// DSNReturn 退信中包含原邮件的哪些内容，MAIL 命令的 RET 参数。
+type DSNReturn string
+
+const (
+	DSNReturnFull    DSNReturn = "FULL" // 完整邮件
+	DSNReturnHeaders DSNReturn = "HDRS" // 仅邮件头
+)
+
+// DSNNotify 在哪些情况下发送投递状态通知，RCPT 命令的 NOTIFY 参数。
+type DSNNotify string
+
+const (
+	DSNNotifyNever   DSNNotify = "NEVER" // 不发送通知，不能与其他值同时使用
+	DSNNotifySuccess DSNNotify = "SUCCESS"
+	DSNNotifyFailure DSNNotify = "FAILURE"
+	DSNNotifyDelay   DSNNotify = "DELAY"
+)
+
+// DSN 投递状态通知（Delivery Status Notification）参数，RFC 3461。
+//
+// 仅在服务器宣告 DSN 扩展时发送，否则忽略。
+type DSN struct {
+	// Return MAIL 命令的 RET 参数，为空时不发送。
+	Return DSNReturn
+	// EnvelopeID MAIL 命令的 ENVID 参数，会原样出现在退信中，便于关联原邮件。为空时不发送。
+	EnvelopeID string
+	// Notify 每个 RCPT 命令的 NOTIFY 参数，为空时不发送。
+	Notify []DSNNotify
+	// OriginalRecipient 为 true 时，每个 RCPT 命令附加 ORCPT 参数，值为收件人的原始地址。
+	OriginalRecipient bool
+}
+
+// validate 检查参数是否符合 RFC 3461。
+func (d *DSN) validate() error {
+	switch d.Return {
+	case "", DSNReturnFull, DSNReturnHeaders:
+	default:
+		return fmt.Errorf("goemail: invalid DSN RET value %q", d.Return)
+	}
+	if len(d.EnvelopeID) > 100 {
+		return errors.New("goemail: DSN ENVID must not exceed 100 characters")
+	}
+	if !isASCII(d.EnvelopeID) {
+		return errors.New("goemail: DSN ENVID must be ASCII")
+	}
+	for _, n := range d.Notify {
+		switch n {
+		case DSNNotifySuccess, DSNNotifyFailure, DSNNotifyDelay:
+		case DSNNotifyNever:
+			if len(d.Notify) > 1 {
+				return errors.New("goemail: DSN NOTIFY=NEVER cannot be combined with other values")
+			}
+		default:
+			return fmt.Errorf("goemail: invalid DSN NOTIFY value %q", n)
+		}
+	}
+	return nil
+}
+
+// mailParams 返回 MAIL 命令的 DSN 参数。
+func (d *DSN) mailParams() []string {
+	var params []string
+	if d.Return != "" {
+		params = append(params, "RET="+string(d.Return))
+	}
+	if d.EnvelopeID != "" {
+		params = append(params, "ENVID="+xtext(d.EnvelopeID))
+	}
+	return params
+}
+
+// rcptParams 返回 RCPT 命令的 DSN 参数，addr 为收件人的原始地址。
+func (d *DSN) rcptParams(addr string) []string {
+	var params []string
+	if len(d.Notify) > 0 {
+		notify := make([]string, len(d.Notify))
+		for i, n := range d.Notify {
+			notify[i] = string(n)
+		}
+		params = append(params, "NOTIFY="+strings.Join(notify, ","))
+	}
+	if d.OriginalRecipient {
+		if isASCII(addr) {
+			params = append(params, "ORCPT=rfc822;"+xtext(addr))
+		} else {
+			params = append(params, "ORCPT=utf-8;"+utf8AddrXtext(addr)) // RFC 6533
+		}
+	}
+	return params
+}
+
+// xtext 按 RFC 3461 第 4 节编码参数值："+"、"=" 以及非可打印字符编码为 "+XX"。
+func xtext(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '+' || c == '=' {
+			fmt.Fprintf(&b, "+%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// utf8AddrXtext 按 RFC 6533 编码 UTF-8 地址："+"、"=" 以及控制字符编码为 "\x{XX}"，
+// 其余 UTF-8 字符原样保留。
+func utf8AddrXtext(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < '!' || r == '+' || r == '=' || r == '\\' || r == 0x7f {
+			fmt.Fprintf(&b, `\x{%X}`, r)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
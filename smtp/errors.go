@@ -0,0 +1,166 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrNotConnected 表示 SMTPSender 尚未连接 SMTP 服务器，或连接已关闭。
+	ErrNotConnected = errors.New("SMTP 客户端未连接")
+	// ErrConnectionLost 表示发送前的 NOOP 检查失败，连接可能已断开。
+	ErrConnectionLost = errors.New("NOOP 命令失败, 连接可能已断开")
+	// ErrNoRecipients 表示信封中没有收件人。
+	ErrNoRecipients = errors.New("goemail: no recipients")
+	// ErrMissingFrom 表示邮件头中没有 From 字段，无法获取发件人。
+	ErrMissingFrom = errors.New(`goemail: invalid message, "From" field is absent`)
+	// ErrNoAuthMechanism 表示服务器不支持 SMTP.AuthMechanisms 中的任何认证机制。
+	ErrNoAuthMechanism = errors.New("goemail: no supported authentication mechanism")
+	// ErrTLSRequired 表示 TLSPolicy 为 TLSMandatory，而服务器不支持 STARTTLS。
+	ErrTLSRequired = errors.New("goemail: TLS is required but the server does not support STARTTLS")
+	// ErrInsecureAuth 表示拒绝在未加密的连接上认证，见 SMTP.AllowInsecureAuth。
+	ErrInsecureAuth = errors.New("goemail: refusing to authenticate over an unencrypted connection")
+	// ErrFingerprintMismatch 表示服务器证书与 SMTP.TLSFingerprints 中的指纹都不相同。
+	ErrFingerprintMismatch = errors.New("goemail: server certificate fingerprint mismatch")
+	// ErrLMTPAuth 表示 LMTP 模式不支持 AUTH，LMTP 只应用于本机或可信网络中的投递。
+	ErrLMTPAuth = errors.New("goemail: AUTH is not supported in LMTP mode")
+	// ErrNullMX 表示收件人域名以 null MX 记录（RFC 7505）声明不接收邮件。
+	ErrNullMX = errors.New("goemail: domain does not accept mail (null MX)")
+	// ErrNoMailHost 表示收件人域名既没有 MX 记录，也没有 A/AAAA 记录。
+	ErrNoMailHost = errors.New("goemail: no mail host for domain")
+	// ErrNoRelayAvailable 表示 FailoverSender 的所有中继都发送失败或处于熔断状态。
+	ErrNoRelayAvailable = errors.New("goemail: no relay available")
+	// ErrQuotaExhausted 表示 Balancer 的所有账号都已用尽当日或当前小时的配额。
+	ErrQuotaExhausted = errors.New("goemail: sending quota of all accounts is exhausted")
+	// ErrPoolClosed 表示连接池已关闭。
+	ErrPoolClosed = errors.New("goemail: sender pool is closed")
+	// ErrPoolTimeout 表示在 PoolConfig.MaxWait 内没有可用的连接。
+	ErrPoolTimeout = errors.New("goemail: no available sender in pool")
+	// ErrRateLimited 表示超过 Throttle 的发送速率，Throttle.Mode 为 ThrottleFail 时返回。
+	ErrRateLimited = errors.New("goemail: rate limit exceeded")
+	// ErrDispatcherClosed 表示 Dispatcher 已调用 Shutdown，不再接受邮件。
+	ErrDispatcherClosed = errors.New("goemail: dispatcher is shut down")
+	// ErrDispatcherFull 表示 Dispatcher 的缓冲区已满，TrySubmit 时返回。
+	ErrDispatcherFull = errors.New("goemail: dispatcher buffer is full")
+)
+
+// SMTPError 是 SMTP 服务器对某条命令的错误响应。
+//
+// 可以使用 errors.As 从 smtp 包返回的错误中取得 *SMTPError，
+// 或使用 IsTemporary、IsPermanent、IsAuthFailure 判断错误类型。
+type SMTPError struct {
+	// Command 出错的命令，如 "MAIL"、"RCPT"、"DATA"、"AUTH"；服务器问候出错时为空。
+	Command string
+	// Code 基本响应码，如 550。
+	Code int
+	// EnhancedCode RFC 3463 增强状态码，如 "5.1.1"；服务器未提供时为空。
+	EnhancedCode string
+	// Message 响应文本，不含增强状态码。
+	Message string
+}
+
+func (e *SMTPError) Error() string {
+	var b strings.Builder
+	b.WriteString("smtp: ")
+	if e.Command != "" {
+		b.WriteString(e.Command + ": ")
+	}
+	fmt.Fprintf(&b, "%03d", e.Code)
+	if e.EnhancedCode != "" {
+		b.WriteString(" " + e.EnhancedCode)
+	}
+	if e.Message != "" {
+		b.WriteString(" " + e.Message)
+	}
+	return b.String()
+}
+
+// PartialDeliveryError 表示 LMTP 服务器已将邮件投递给部分收件人，其余收件人失败。
+// 重新发送整封邮件会使已投递的收件人收到重复的邮件，因此 IsRetryable 返回 false，
+// FailoverSender、Balancer 也不再尝试其他服务器；需要重试时，只对 Result 中失败的收件人重新发送。
+type PartialDeliveryError struct {
+	// Result 每个收件人的投递结果。
+	Result *SendResult
+	// Err 第一个失败收件人的错误。
+	Err error
+}
+
+func (e *PartialDeliveryError) Error() string {
+	return fmt.Sprintf("goemail: delivered to %d of %d recipients: %v",
+		len(e.Result.Accepted), len(e.Result.Accepted)+len(e.Result.Failed()), e.Err)
+}
+
+func (e *PartialDeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary 报告错误是否为暂时性的（4xx），稍后重试可能成功。
+func (e *SMTPError) Temporary() bool {
+	return e.Code >= 400 && e.Code < 500
+}
+
+// Permanent 报告错误是否为永久性的（5xx），重试不会成功。
+func (e *SMTPError) Permanent() bool {
+	return e.Code >= 500 && e.Code < 600
+}
+
+// IsTemporary 报告 err 是否为服务器的暂时性错误响应（4xx）。
+func IsTemporary(err error) bool {
+	var e *SMTPError
+	return errors.As(err, &e) && e.Temporary()
+}
+
+// IsPermanent 报告 err 是否为服务器的永久性错误响应（5xx）。
+func IsPermanent(err error) bool {
+	var e *SMTPError
+	return errors.As(err, &e) && e.Permanent()
+}
+
+// IsAuthFailure 报告 err 是否为认证失败：AUTH 命令被拒绝，
+// 或响应码为 530、535，或增强状态码为 5.7.8（认证凭据无效）。
+func IsAuthFailure(err error) bool {
+	var e *SMTPError
+	if !errors.As(err, &e) {
+		return false
+	}
+	return (e.Command == "AUTH" && e.Code >= 400) ||
+		e.Code == 530 || e.Code == 535 || e.EnhancedCode == "5.7.8"
+}
+
+// enhancedCodeRe 匹配响应行开头的增强状态码，RFC 3463。
+var enhancedCodeRe = regexp.MustCompile(`^([245]\.\d{1,3}\.\d{1,3})(?:\s+|$)`)
+
+// newSMTPError 将 net/textproto 的错误响应转换为 *SMTPError，其他错误原样返回。
+func newSMTPError(command string, err error) error {
+	var tpErr *textproto.Error
+	if !errors.As(err, &tpErr) {
+		return err
+	}
+
+	e := &SMTPError{Command: command, Code: tpErr.Code}
+	e.EnhancedCode, e.Message = parseEnhancedCode(tpErr.Msg)
+	return e
+}
+
+// parseEnhancedCode 从响应文本中分离出增强状态码，多行响应的每一行都会去掉增强状态码。
+func parseEnhancedCode(msg string) (code, text string) {
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		if m := enhancedCodeRe.FindStringSubmatch(line); m != nil {
+			if code == "" {
+				code = m[1]
+			}
+			lines[i] = line[len(m[0]):]
+		}
+	}
+	return code, strings.Join(lines, "\n")
+}
+
+// commandOf 返回命令行中的命令名，如 "MAIL FROM:<a@b.c>" -> "MAIL"。
+func commandOf(line string) string {
+	verb, _, _ := strings.Cut(line, " ")
+	return strings.ToUpper(verb)
+}
@@ -5,4 +5,13 @@ type Extension = string
 const (
 	SMTPExtAuth     Extension = "AUTH"
 	SMTPExtStartTLS Extension = "STARTTLS"
+	// SMTPExtPipelining 命令流水线，RFC 2920
+	SMTPExtPipelining Extension = "PIPELINING"
+	SMTPExt8BitMIME   Extension = "8BITMIME"
+	SMTPExtSMTPUTF8   Extension = "SMTPUTF8"
+	// SMTPExtChunking BDAT 命令，RFC 3030
+	SMTPExtChunking   Extension = "CHUNKING"
+	SMTPExtBinaryMIME Extension = "BINARYMIME"
+	// SMTPExtDSN 投递状态通知，RFC 3461
+	SMTPExtDSN Extension = "DSN"
 )
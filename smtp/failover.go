@@ -0,0 +1,232 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/JiuYu77/go-email/utils"
+)
+
+const (
+	defaultFailureThreshold = 1
+	defaultCooldown         = time.Minute
+)
+
+// FailoverSender 按顺序使用多个中继服务器发送邮件：当前中继连接失败、TLS 握手或认证失败、连接中断，
+// 或返回暂时性错误（4xx）时，尝试下一个中继；其他错误，如永久性错误（5xx）、附件读取失败、
+// 超过 Throttle 的速率等，与中继无关，直接返回，不影响熔断器。
+//
+// 每个中继有一个熔断器：连续失败 FailureThreshold 次后熔断，Cooldown 时间内跳过该中继；
+// 冷却结束后再次尝试，成功则恢复，失败则重新熔断。
+//
+// 邮件内容发送完毕后连接中断时，无法确定中继是否已接收邮件，此时尝试下一个中继可能导致重复投递。
+//
+// FailoverSender 可以在多个 goroutine 中同时使用。
+type FailoverSender struct {
+	// FailureThreshold 连续失败多少次后熔断，默认 1。
+	FailureThreshold int
+	// Cooldown 熔断后跳过中继的时间，默认 1 分钟。
+	Cooldown time.Duration
+
+	relays []*relay
+}
+
+// relay 一个中继服务器及其熔断器状态。
+type relay struct {
+	smtp *SMTP
+	name string
+
+	mtx       sync.Mutex
+	failures  int       // 连续失败次数
+	openUntil time.Time // 熔断结束时间
+}
+
+// RelayStatus 中继服务器的熔断器状态。
+type RelayStatus struct {
+	Relay     string    `json:"relay"`      // 中继地址 host:port
+	Failures  int       `json:"failures"`   // 连续失败次数
+	OpenUntil time.Time `json:"open_until"` // 熔断结束时间，未熔断时为零值
+}
+
+// FailoverResult 一次发送的结果。
+type FailoverResult struct {
+	// Relay 接收邮件的中继地址 host:port。
+	Relay string `json:"relay"`
+	// Index 接收邮件的中继在中继列表中的下标。
+	Index int `json:"index"`
+	// Errors 在此之前尝试失败的中继及其错误，中继地址 -> 错误。
+	Errors map[string]error `json:"-"`
+}
+
+// NewFailoverSender 使用中继配置列表创建 FailoverSender，按列表顺序尝试。
+func NewFailoverSender(configs ...*SMTPConfig) *FailoverSender {
+	smtps := make([]*SMTP, len(configs))
+	for i, c := range configs {
+		smtps[i] = NewSMTP(c.Host, c.Port, c.Username, c.Password, c.From)
+	}
+	return NewFailoverSender1(smtps...)
+}
+
+// NewFailoverSender1 使用 SMTP 列表创建 FailoverSender，按列表顺序尝试；
+// 每个中继使用对应 SMTP 的全部设置，如 TLSPolicy、TokenSource、超时时间等。
+func NewFailoverSender1(smtps ...*SMTP) *FailoverSender {
+	f := &FailoverSender{}
+	for _, s := range smtps {
+		f.relays = append(f.relays, &relay{smtp: s, name: addr(s.host, s.port)})
+	}
+	return f
+}
+
+// Send 发送邮件 m。
+//
+// Args
+//   - whereFrom: true 使用各中继配置中的发件人，false 从消息中获取发件人。
+//   - m: 邮件内容
+func (f *FailoverSender) Send(whereFrom bool, m *Message) (*FailoverResult, error) {
+	return f.SendContext(context.Background(), whereFrom, m)
+}
+
+// SendContext 与 Send 相同，ctx 作用于所有中继的连接与发送。
+func (f *FailoverSender) SendContext(ctx context.Context, whereFrom bool, m *Message) (*FailoverResult, error) {
+	return f.send(ctx, func(s *SMTP) (*Envelope, error) {
+		return s.envelope(whereFrom, m)
+	}, m)
+}
+
+// SendEnvelope 按信封 env 发送邮件 msg，env.From 为空时使用各中继配置中的发件人。
+func (f *FailoverSender) SendEnvelope(ctx context.Context, env *Envelope, msg io.WriterTo) (*FailoverResult, error) {
+	return f.send(ctx, func(s *SMTP) (*Envelope, error) {
+		if env.From != "" {
+			return env, nil
+		}
+		e := *env
+		e.From = s.from
+		return &e, nil
+	}, msg)
+}
+
+// SendMessage 实现 Transport，同 SendContext(ctx, false, m)。
+func (f *FailoverSender) SendMessage(ctx context.Context, m *Message) error {
+	_, err := f.SendContext(ctx, false, m)
+	return err
+}
+
+// SendRaw 实现 Transport，同 SendEnvelope。
+func (f *FailoverSender) SendRaw(ctx context.Context, env *Envelope, msg []byte) error {
+	_, err := f.SendEnvelope(ctx, env, rawMessage(msg))
+	return err
+}
+
+func (f *FailoverSender) send(ctx context.Context, envelope func(*SMTP) (*Envelope, error), msg io.WriterTo) (*FailoverResult, error) {
+	result := &FailoverResult{Index: -1, Errors: make(map[string]error)}
+	var errs []error
+	for i, r := range f.relays {
+		if !r.allow() {
+			utils.Logger.Debugf(utils.LogPrefix+"relay %s is skipped, circuit open", r.name)
+			continue
+		}
+
+		env, err := envelope(r.smtp)
+		if err != nil {
+			return result, err
+		}
+		dialed, err := dialAndSendEnvelope(ctx, r.smtp, env, msg)
+		if err == nil {
+			r.succeed()
+			result.Relay, result.Index = r.name, i
+			return result, nil
+		}
+
+		result.Errors[r.name] = err
+		errs = append(errs, fmt.Errorf("%s: %w", r.name, err))
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if !shouldFailover(err, dialed) {
+			return result, err
+		}
+		r.fail(f.failureThreshold(), f.cooldown())
+		utils.Logger.Debugf(utils.LogPrefix+"relay %s failed, try next relay: %v", r.name, err)
+	}
+	return result, fmt.Errorf("%w: %w", ErrNoRelayAvailable, errors.Join(errs...))
+}
+
+// shouldFailover 报告 err 是否为中继本身的故障，应尝试下一个中继：连接、TLS 握手或认证失败
+// （dialed 为 false），连接中断，或服务器返回暂时性错误（4xx）。
+// 邮件本身的错误（如附件读取失败、永久性拒绝）与本地错误（如 ErrRateLimited）换一个中继也无法发送。
+func shouldFailover(err error, dialed bool) bool {
+	if !dialed {
+		return true
+	}
+	var partial *PartialDeliveryError
+	if errors.As(err, &partial) {
+		return false
+	}
+	// 连接上的读写错误为 *net.OpError；不能用 net.Error 判断，*fs.PathError 也实现了它
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, ErrConnectionLost) || errors.Is(err, ErrNotConnected) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || IsTemporary(err)
+}
+
+// Relays 返回每个中继的熔断器状态，顺序与创建时相同。
+func (f *FailoverSender) Relays() []RelayStatus {
+	status := make([]RelayStatus, len(f.relays))
+	for i, r := range f.relays {
+		r.mtx.Lock()
+		status[i] = RelayStatus{Relay: r.name, Failures: r.failures, OpenUntil: r.openUntil}
+		r.mtx.Unlock()
+	}
+	return status
+}
+
+func (f *FailoverSender) failureThreshold() int {
+	if f.FailureThreshold <= 0 {
+		return defaultFailureThreshold
+	}
+	return f.FailureThreshold
+}
+
+func (f *FailoverSender) cooldown() time.Duration {
+	if f.Cooldown <= 0 {
+		return defaultCooldown
+	}
+	return f.Cooldown
+}
+
+// dialAndSendEnvelope 连接 s 的服务器并发送邮件；dialed 报告连接（包括 TLS 握手与认证）是否成功。
+func dialAndSendEnvelope(ctx context.Context, s *SMTP, env *Envelope, msg io.WriterTo) (dialed bool, err error) {
+	sender, err := s.DialContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer sender.Quit()
+	return true, sender.SendEnvelope(ctx, env, msg)
+}
+
+// allow 报告中继是否可用：未熔断，或熔断已过冷却时间。
+func (r *relay) allow() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return !time.Now().Before(r.openUntil)
+}
+
+func (r *relay) succeed() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.failures = 0
+	r.openUntil = time.Time{}
+}
+
+func (r *relay) fail(threshold int, cooldown time.Duration) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.failures++
+	if r.failures >= threshold {
+		r.openUntil = time.Now().Add(cooldown)
+	}
+}
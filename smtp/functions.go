@@ -1,9 +1,10 @@
 package smtp
 
 import (
-	"fmt"
+	"net"
+	"strconv"
 )
 
 func addr(host string, port int) string {
-	return fmt.Sprintf("%s:%d", host, port)
+	return net.JoinHostPort(host, strconv.Itoa(port))
 }
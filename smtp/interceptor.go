@@ -0,0 +1,97 @@
+package smtp
+
+import (
+	"context"
+	"io"
+	"slices"
+	"time"
+)
+
+// SendRequest 一次发送的请求，拦截器可以修改其中的信封与邮件。
+type SendRequest struct {
+	// Envelope 信封：发件人、收件人及 DSN 参数，修改后按新的信封发送。
+	Envelope *Envelope
+	// Message 发送的邮件，邮件内容不是 *Message（如 Send1 的 []byte）时为 nil。
+	// Message 是调用者传入的 *Message 的副本，可以修改，如 Message.SetHeader，或替换为另一封邮件；
+	// 修改不影响调用者，重试时每次都从调用者的邮件重新复制。
+	Message *Message
+	// Body 实际写入的邮件内容，Message 不为 nil 时与 Message 相同。
+	// 替换 Body 后发送新的内容；只替换 Message 时发送新的 Message。
+	Body io.WriterTo
+	// Partial 是否为 SendPartial：个别收件人被拒绝时继续发送。
+	Partial bool
+}
+
+// SendFunc 执行一次发送，返回每个收件人的结果。
+type SendFunc func(ctx context.Context, req *SendRequest) (*SendResult, error)
+
+// Interceptor 发送拦截器：在 next 之前检查、修改或拒绝请求（返回错误而不调用 next），
+// 在 next 之后观察结果。多个拦截器依次嵌套，先添加的在外层。
+//
+// 拦截器作用于 SMTPSender 的全部发送方法（Send、SendEmail、SendEnvelope、SendPartial 等），
+// 以及经 SMTP、SMTPSenderPool、Dispatcher 等发送的邮件。
+type Interceptor func(ctx context.Context, req *SendRequest, next SendFunc) (*SendResult, error)
+
+// BeforeSend 创建在发送前调用 fn 的拦截器：fn 可以修改 req，返回错误时不发送，并返回该错误。
+func BeforeSend(fn func(ctx context.Context, req *SendRequest) error) Interceptor {
+	return func(ctx context.Context, req *SendRequest, next SendFunc) (*SendResult, error) {
+		if err := fn(ctx, req); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// AfterSend 创建在发送后调用 fn 的拦截器，fn 得到发送的结果、错误与耗时，如用于日志与统计。
+func AfterSend(fn func(ctx context.Context, req *SendRequest, result *SendResult, err error, elapsed time.Duration)) Interceptor {
+	return func(ctx context.Context, req *SendRequest, next SendFunc) (*SendResult, error) {
+		start := time.Now()
+		result, err := next(ctx, req)
+		fn(ctx, req, result, err, time.Since(start))
+		return result, err
+	}
+}
+
+// Use 添加拦截器，作用于此后经 s 建立的全部连接。
+func (s *SMTP) Use(interceptors ...Interceptor) {
+	s.Interceptors = append(s.Interceptors, interceptors...)
+}
+
+// Use 添加只作用于本连接的拦截器，在 SMTP.Interceptors 之内调用。
+func (s *SMTPSender) Use(interceptors ...Interceptor) {
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+// intercept 经 SMTP.Interceptors 与本连接的拦截器发送邮件 msg。
+func (s *SMTPSender) intercept(ctx context.Context, env *Envelope, msg io.WriterTo, partial bool) (*SendResult, error) {
+	chain := append(append([]Interceptor(nil), s.smtp.Interceptors...), s.interceptors...)
+	if len(chain) == 0 {
+		return s.transact(ctx, env, msg, partial)
+	}
+
+	// 复制信封与邮件，拦截器的修改不影响调用者
+	e := *env
+	e.To = slices.Clone(env.To)
+	req := &SendRequest{Envelope: &e, Body: msg, Partial: partial}
+	if m, ok := msg.(*Message); ok && m != nil {
+		req.Message = m.clone()
+		req.Body = req.Message
+	}
+	message := req.Message
+
+	send := SendFunc(func(ctx context.Context, req *SendRequest) (*SendResult, error) {
+		body := req.Body
+		if req.Message != nil && req.Message != message && body == io.WriterTo(message) {
+			body = req.Message // 只替换了 Message
+		}
+		return s.transact(ctx, req.Envelope, body, req.Partial)
+	})
+	for i := len(chain) - 1; i >= 0; i-- {
+		interceptor, next := chain[i], send
+		send = func(ctx context.Context, req *SendRequest) (*SendResult, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+
+	return send(ctx, req)
+}
@@ -0,0 +1,65 @@
+package smtp
+
+import "strings"
+
+// LMTP（Local Mail Transfer Protocol，RFC 2033）用于把邮件交给本机的投递代理，如 Dovecot、Postfix。
+// 与 SMTP 的区别：以 LHLO 代替 EHLO；邮件内容发送完毕后，服务器为每个已接收的收件人各返回一条响应。
+//
+// net/smtp 的 Client 会在需要时自动发送 EHLO，因此 LMTP 模式下不使用它的 Hello、Extension、
+// Noop、Quit、StartTLS、Auth 等方法，而是直接通过 Client.Text 发送命令。
+
+// lhlo 发送 LHLO 命令，并记录服务器支持的扩展。
+func (s *SMTPSender) lhlo(name string) error {
+	if name == "" {
+		name = "localhost"
+	}
+	_, msg, err := s.cmd(250, "LHLO %s", name)
+	if err != nil {
+		return err
+	}
+
+	ext := make(map[string]string)
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines[1:] { // 第一行是服务器的问候
+		k, v, _ := strings.Cut(line, " ")
+		ext[strings.ToUpper(k)] = v
+	}
+	s.lmtpExt = ext
+	return nil
+}
+
+// dataReplies 读取邮件内容发送完毕后服务器的响应，command 为 DATA 或 BDAT。
+// SMTP 只有一条响应；LMTP 为 n 个已接收的收件人各有一条响应，按 RCPT 命令的顺序排列。
+// 返回的 error 为第一个错误。
+func (s *SMTPSender) dataReplies(command string, n int) ([]reply, error) {
+	if !s.smtp.LMTP {
+		n = 1
+	}
+	replies := make([]reply, n)
+	var firstErr error
+	for i := range replies {
+		rp, err := readReply(s.client.Text, command, 250)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = rp
+		if firstErr == nil {
+			firstErr = rp.err
+		}
+	}
+	return replies, firstErr
+}
+
+// lmtpReplies 用 LMTP 对邮件内容的逐个响应 final，替换已接收收件人的 RCPT 响应。
+func lmtpReplies(rcpt, final []reply) []reply {
+	out := make([]reply, len(rcpt))
+	k := 0
+	for i, rp := range rcpt {
+		if rp.err == nil && k < len(final) {
+			rp = final[k]
+			k++
+		}
+		out[i] = rp
+	}
+	return out
+}
@@ -2,12 +2,12 @@ package smtp
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"io"
 	"net/mail"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 )
 
@@ -51,6 +51,23 @@ func (m *Message) Reset() {
 	m.attachments = nil
 	m.embedded = nil
 }
+
+// clone 复制邮件，修改副本的邮件头、正文、附件列表不影响 m；正文与附件的内容本身共享。
+func (m *Message) clone() *Message {
+	c := &Message{
+		header:        make(Header, len(m.header)),
+		parts:         slices.Clone(m.parts),
+		attachments:   slices.Clone(m.attachments),
+		embedded:      slices.Clone(m.embedded),
+		charset:       m.charset,
+		encoding:      m.encoding,
+		headerEncoder: m.headerEncoder,
+	}
+	for k, v := range m.header {
+		c.header[k] = slices.Clone(v)
+	}
+	return c
+}
 func (m *Message) applySettings(settings []MessageSetting) {
 	for _, s := range settings {
 		s(m)
@@ -87,11 +104,24 @@ func parseAddress(address string) (string, error) {
 func (m *Message) getFrom() (string, error) {
 	from := m.header["From"]
 	if len(from) == 0 {
-		return "", errors.New(`[geomail] invalid message, "From" field is absent`)
+		return "", ErrMissingFrom
 	}
 	return parseAddress(from[0])
 }
 
+// Envelope 根据邮件头生成信封：发件人取自 From，收件人取自 To、Cc、Bcc。
+func (m *Message) Envelope() (*Envelope, error) {
+	from, err := m.getFrom()
+	if err != nil {
+		return nil, fmt.Errorf("获取发件人失败: %w", err)
+	}
+	to, err := m.getRecipients()
+	if err != nil {
+		return nil, fmt.Errorf("获取收件人失败: %w", err)
+	}
+	return &Envelope{From: from, To: to}, nil
+}
+
 // SetTo 设置收件人
 func (m *Message) SetTo(to []string) {
 	m.setHeader("To", to...)
@@ -197,11 +227,27 @@ func (m *Message) setHeaderAdderss(key, addr string, name string) {
 	m.header[key] = []string{m.FormatAddress(addr, name)}
 }
 
+// encodeAddresses 编码地址头信息：只编码显示名称，地址保持原样，
+// 以便国际化邮箱地址（如 用户@例子.中国）可以被正确解析与发送（RFC 6532）。
+func (m *Message) encodeAddresses(values []string) {
+	for i, v := range values {
+		addr, err := mail.ParseAddress(v)
+		if err != nil {
+			values[i] = m.encodeString(v)
+			continue
+		}
+		values[i] = m.FormatAddress(addr.Address, addr.Name)
+	}
+}
+
 // setHeader 设置邮件头
 func (m *Message) setHeader(key string, value ...string) {
 	switch key {
 	case "From":
 		m.setHeaderAdderss(key, value[0], value[1])
+	case "To", "Cc", "Bcc", "Reply-To", "Sender":
+		m.encodeAddresses(value)
+		m.header[key] = value
 	default:
 		m.encodeHeader(value)
 		m.header[key] = value
@@ -313,10 +359,22 @@ func (m *Message) AddAlternativeWriter(contentType string, f func(io.Writer) err
 // @return n int64 写入的字节数
 // @return err error 写入错误, nil 表示写入成功
 func (m *Message) WriteTo(w io.Writer) (n int64, err error) {
-	msgWriter := MessageWriter{
-		w: w,
-	}
+	return m.writeTo(&MessageWriter{w: w})
+}
+
+// writeTo 使用配置好的 MessageWriter 写入邮件，发送时可据服务器支持的扩展调整输出格式。
+func (m *Message) writeTo(msgWriter *MessageWriter) (n int64, err error) {
 	msgWriter.writeMessage(m)
 
 	return msgWriter.n, msgWriter.err
 }
+
+// has8bitParts 报告邮件正文是否含有未编码（8bit）的部分。
+func (m *Message) has8bitParts() bool {
+	for _, p := range m.parts {
+		if p.encoding == Unencoded {
+			return true
+		}
+	}
+	return false
+}
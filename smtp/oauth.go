@@ -0,0 +1,285 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JiuYu77/go-email/utils"
+)
+
+// TokenSource 提供 OAuth 2.0 访问令牌，XOAUTH2 与 OAUTHBEARER 认证机制每次认证都会调用 Token，
+// 因此长时间运行的 SMTPSender、SMTPSenderPool 在令牌过期后重新连接时能使用新的令牌。
+//
+// Token 可能被多个连接并发调用，实现需要保证并发安全。
+// 实现了 TokenSourceContext 时改为调用 TokenContext，传入建立连接的 ctx。
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// TokenSourceContext 由获取令牌可能较慢（如需要请求令牌端点）的 TokenSource 实现，
+// 取消 ctx 或 ctx 超时时 TokenContext 应尽快返回。
+type TokenSourceContext interface {
+	TokenSource
+	TokenContext(ctx context.Context) (string, error)
+}
+
+// sourceToken 从 source 获取令牌，source 实现了 TokenSourceContext 时使用 ctx，ctx 为 nil 时使用 context.Background()。
+func sourceToken(ctx context.Context, source TokenSource) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if sc, ok := source.(TokenSourceContext); ok {
+		return sc.TokenContext(ctx)
+	}
+	return source.Token()
+}
+
+// tokenInvalidator 由可以丢弃缓存令牌的 TokenSource 实现，认证失败时调用，
+// 使下一次认证重新获取令牌。
+type tokenInvalidator interface {
+	Invalidate()
+}
+
+// StaticTokenSource 总是返回同一个令牌。
+type StaticTokenSource string
+
+func (t StaticTokenSource) Token() (string, error) {
+	return string(t), nil
+}
+
+// expiryDelta 令牌在过期前多久就视为已过期，避免令牌在认证过程中过期。
+const expiryDelta = time.Minute
+
+// RefreshTokenSource 使用 refresh token 向令牌端点（RFC 6749 第 6 节）获取访问令牌，
+// 并缓存到过期前；服务器返回新的 refresh token 时自动替换。
+type RefreshTokenSource struct {
+	// TokenURL 令牌端点，如 "https://oauth2.googleapis.com/token"。
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	// Scopes 请求的权限范围，为空时不发送。
+	Scopes []string
+	// HTTPClient 请求令牌端点使用的 HTTP 客户端，nil 时使用超时 30 秒的默认客户端。
+	HTTPClient *http.Client
+
+	mtx         sync.Mutex
+	accessToken string
+	expiry      time.Time     // 零值表示不会过期
+	refreshing  *tokenRefresh // 正在进行的刷新，nil 表示没有
+}
+
+// tokenRefresh 一次正在进行的刷新，完成后关闭 done；同时需要令牌的调用者等待它，而不是各自请求令牌端点。
+type tokenRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRefreshTokenSource 创建一个 RefreshTokenSource。
+func NewRefreshTokenSource(tokenURL, clientID, clientSecret, refreshToken string) *RefreshTokenSource {
+	return &RefreshTokenSource{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RefreshToken: refreshToken,
+	}
+}
+
+// Token 返回缓存的访问令牌，令牌不存在或即将过期时向令牌端点刷新。
+func (ts *RefreshTokenSource) Token() (string, error) {
+	return ts.TokenContext(context.Background())
+}
+
+// TokenContext 与 Token 相同，ctx 作用于对令牌端点的请求。
+// 刷新期间不持有锁：同时调用的其他 goroutine 等待这次刷新的结果，或在各自的 ctx 结束时返回。
+func (ts *RefreshTokenSource) TokenContext(ctx context.Context) (string, error) {
+	for {
+		ts.mtx.Lock()
+		if ts.accessToken != "" && (ts.expiry.IsZero() || time.Now().Before(ts.expiry)) {
+			token := ts.accessToken
+			ts.mtx.Unlock()
+			return token, nil
+		}
+		if r := ts.refreshing; r != nil {
+			ts.mtx.Unlock()
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			// 发起刷新的调用者的 ctx 结束时，由等待者重新刷新；其他错误直接返回
+			if r.err != nil && !errors.Is(r.err, context.Canceled) && !errors.Is(r.err, context.DeadlineExceeded) {
+				return "", r.err
+			}
+			continue
+		}
+
+		r := &tokenRefresh{done: make(chan struct{})}
+		ts.refreshing = r
+		refreshToken := ts.RefreshToken
+		ts.mtx.Unlock()
+
+		tr, err := ts.refresh(ctx, refreshToken)
+
+		ts.mtx.Lock()
+		ts.refreshing = nil
+		if err == nil {
+			ts.accessToken = tr.AccessToken
+			ts.expiry = time.Time{}
+			if tr.ExpiresIn > 0 {
+				ts.expiry = time.Now().Add(time.Duration(tr.ExpiresIn)*time.Second - expiryDelta)
+			}
+			if tr.RefreshToken != "" {
+				ts.RefreshToken = tr.RefreshToken
+			}
+		}
+		ts.mtx.Unlock()
+		r.err = err
+		close(r.done)
+		if err != nil {
+			return "", err
+		}
+		return tr.AccessToken, nil
+	}
+}
+
+// Invalidate 丢弃缓存的访问令牌，下一次调用 Token 时重新刷新。
+// 服务器拒绝令牌时（如令牌被提前吊销）会自动调用。
+func (ts *RefreshTokenSource) Invalidate() {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	ts.accessToken = ""
+}
+
+// tokenResponse 令牌端点的响应，RFC 6749 第 5 节。
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	// 错误响应
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// refresh 使用 refreshToken 请求令牌端点，返回成功的响应。
+func (ts *RefreshTokenSource) refresh(ctx context.Context, refreshToken string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {ts.ClientID},
+	}
+	if ts.ClientSecret != "" {
+		form.Set("client_secret", ts.ClientSecret)
+	}
+	if len(ts.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.Scopes, " "))
+	}
+
+	client := ts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("goemail: refresh OAuth2 token: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("goemail: refresh OAuth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("goemail: refresh OAuth2 token: %w", err)
+	}
+
+	var tr tokenResponse
+	jsonErr := json.Unmarshal(body, &tr)
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		if tr.Error != "" {
+			return nil, fmt.Errorf("goemail: refresh OAuth2 token: %s %s: %s", resp.Status, tr.Error, tr.ErrorDescription)
+		}
+		return nil, fmt.Errorf("goemail: refresh OAuth2 token: %s", resp.Status)
+	}
+	if jsonErr != nil {
+		return nil, fmt.Errorf("goemail: refresh OAuth2 token: %w", jsonErr)
+	}
+	if tr.AccessToken == "" {
+		return nil, errors.New("goemail: refresh OAuth2 token: response has no access_token")
+	}
+
+	return &tr, nil
+}
+
+// oauthError 服务器拒绝令牌时，在认证挑战中返回的 JSON，RFC 7628 第 3.2.2 节。
+type oauthError struct {
+	Status  string `json:"status"`
+	Schemes string `json:"schemes"`
+	Scope   string `json:"scope"`
+}
+
+// handleOAuthError 处理服务器的错误挑战：记录错误并使令牌失效。
+// 随后客户端按协议回复一条固定的消息，服务器以 535 等错误响应结束认证。
+func handleOAuthError(mechanism string, source TokenSource, challenge []byte) {
+	var e oauthError
+	if err := json.Unmarshal(challenge, &e); err == nil {
+		utils.Logger.Debugln(utils.LogPrefix, mechanism, "token rejected, status:", e.Status, "scope:", e.Scope)
+	}
+	if ti, ok := source.(tokenInvalidator); ok {
+		ti.Invalidate()
+	}
+}
+
+// oauthBearerAuth is an smtp.Auth that implements the OAUTHBEARER authentication mechanism.
+// oauthBearerAuth 实现 OAUTHBEARER 认证机制，RFC 7628。
+type oauthBearerAuth struct {
+	username string
+	host     string
+	port     int
+	source   TokenSource
+	ctx      context.Context // 获取令牌使用的 ctx，nil 表示 context.Background()
+}
+
+// OAuthBearerAuth 返回 OAUTHBEARER 认证机制，每次认证都从 source 获取访问令牌。
+// host、port 为 SMTP 服务器的主机名与端口号。
+func OAuthBearerAuth(username, host string, port int, source TokenSource) smtp.Auth {
+	return &oauthBearerAuth{username: username, host: host, port: port, source: source}
+}
+
+func (a *oauthBearerAuth) session(ctx context.Context, _ *tls.ConnectionState) smtp.Auth {
+	s := *a
+	s.ctx = ctx
+	return &s
+}
+
+func (a *oauthBearerAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	token, err := sourceToken(a.ctx, a.source)
+	if err != nil {
+		return "", nil, err
+	}
+	resp := "n,a=" + scramEscape(a.username) + "," +
+		"\x01host=" + a.host + "\x01port=" + strconv.Itoa(a.port) +
+		"\x01auth=Bearer " + token + "\x01\x01"
+	return SMTPAuthOAuthBearer, []byte(resp), nil
+}
+
+func (a *oauthBearerAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	handleOAuthError(SMTPAuthOAuthBearer, a.source, fromServer)
+	return []byte{0x01}, nil // RFC 7628 第 3.2.3 节
+}
@@ -0,0 +1,378 @@
+package smtp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JiuYu77/go-email/utils"
+)
+
+const (
+	defaultPoolMaxWait = 30 * time.Second
+	// defaultPingTimeout CommandTimeout 为 0 时，检查空闲连接（NOOP）最多等待的时间。
+	defaultPingTimeout = 10 * time.Second
+)
+
+// PoolConfig 连接池的设置，零值表示不限制（MaxSize、MaxWait 除外）。
+type PoolConfig struct {
+	// MaxSize 最大连接数，包括空闲连接与正在使用的连接，默认 1。
+	MaxSize int
+	// MaxWait 没有可用连接时 Get 最多等待的时间，默认 30 秒。
+	MaxWait time.Duration
+	// MaxIdleTime 连接空闲超过此时间后关闭。
+	MaxIdleTime time.Duration
+	// MaxLifetime 连接建立超过此时间后关闭，不再放回连接池。
+	MaxLifetime time.Duration
+	// MaxMessages 每个连接最多发送的邮件数，达到后关闭连接。
+	// 很多服务器限制单个连接发送的邮件数，如 100。
+	MaxMessages int
+	// HealthCheckInterval 后台检查空闲连接的间隔：对每个空闲连接发送 NOOP 命令，
+	// 关闭失败、空闲超时或超过生命周期的连接。
+	HealthCheckInterval time.Duration
+}
+
+// PoolStats 连接池的统计信息。
+type PoolStats struct {
+	Open  int `json:"open"`   // 已建立的连接数
+	Idle  int `json:"idle"`   // 空闲连接数
+	InUse int `json:"in_use"` // 正在使用的连接数
+}
+
+// SMTPSenderPool 是一个 SMTP 发送池。
+// 它维护一个连接池, 每个连接都是一个 Sender 实例。
+// 每个连接都有一个 SMTP 客户端, 用于发送邮件。
+//
+// 连接在 Get 时按需建立；Put 放回的连接以 RSET 命令重置会话状态，供下一封邮件使用。
+// SMTPSenderPool 可以在多个 goroutine 中同时使用。
+type SMTPSenderPool struct {
+	smtp   *SMTP // 创建连接使用的 SMTP
+	config PoolConfig
+
+	sem   chan struct{} // 正在使用的连接占用一个位置
+	done  chan struct{} // Close 时关闭
+	inUse sync.WaitGroup
+
+	mtx    sync.Mutex
+	idle   []*SMTPSender // 空闲连接，最近放回的在末尾
+	open   int           // 已建立的连接数
+	closed bool
+}
+
+func NewSMTPSenderPool(poolSize int, config *SMTPConfig) *SMTPSenderPool {
+	smtp := NewSMTP(
+		config.Host, config.Port, config.Username, config.Password, config.From,
+	)
+	return NewSMTPSenderPool1(poolSize, smtp)
+}
+
+// NewSMTPSenderPool1 使用 smtp 创建连接池，池中的连接共享 smtp 的全部设置，
+// 如 TokenSource、AuthMechanisms、超时时间等。
+func NewSMTPSenderPool1(poolSize int, smtp *SMTP) *SMTPSenderPool {
+	return NewSMTPSenderPool2(smtp, PoolConfig{MaxSize: poolSize})
+}
+
+// NewSMTPSenderPool2 使用 smtp 与连接池设置 config 创建连接池。
+// config.HealthCheckInterval 大于 0 时启动后台健康检查，直到 Close。
+func NewSMTPSenderPool2(smtp *SMTP, config PoolConfig) *SMTPSenderPool {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 1
+	}
+	if config.MaxWait <= 0 {
+		config.MaxWait = defaultPoolMaxWait
+	}
+	p := &SMTPSenderPool{
+		smtp:   smtp,
+		config: config,
+		sem:    make(chan struct{}, config.MaxSize),
+		done:   make(chan struct{}),
+	}
+	if config.HealthCheckInterval > 0 {
+		go p.healthCheck()
+	}
+	return p
+}
+
+// Get 从连接池取出一个连接，没有空闲连接时建立新连接；
+// 连接数已达 MaxSize 时最多等待 MaxWait，超时返回 ErrPoolTimeout。
+// 使用完毕后必须调用 Put 放回。
+func (p *SMTPSenderPool) Get() (*SMTPSender, error) {
+	return p.GetContext(context.Background())
+}
+
+// GetContext 与 Get 相同，ctx 用于取消等待与连接过程。
+func (p *SMTPSenderPool) GetContext(ctx context.Context) (*SMTPSender, error) {
+	timer := time.NewTimer(p.config.MaxWait)
+	defer timer.Stop()
+	select {
+	case p.sem <- struct{}{}:
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, ErrPoolTimeout
+	}
+
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		<-p.sem
+		return nil, ErrPoolClosed
+	}
+	p.inUse.Add(1)
+	for len(p.idle) > 0 {
+		sender := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.expired(sender, time.Now()) {
+			p.open--
+			go sender.Quit()
+			continue
+		}
+		p.mtx.Unlock()
+
+		// 空闲期间服务器可能已关闭连接，NOOP 检查失败时关闭该连接，改用其他连接
+		err := sender.ping(ctx)
+		if err == nil {
+			p.take(sender)
+			return sender, nil
+		}
+		utils.Logger.Debugln(utils.LogPrefix, "idle connection is broken, close it:", err)
+		if sender.client != nil {
+			sender.abort()
+		}
+		p.mtx.Lock()
+		p.open--
+		if p.closed {
+			p.mtx.Unlock()
+			p.release()
+			return nil, ErrPoolClosed
+		}
+		if err := ctx.Err(); err != nil {
+			p.mtx.Unlock()
+			p.release()
+			return nil, err
+		}
+	}
+	p.open++
+	p.mtx.Unlock()
+
+	sender, err := p.smtp.DialContext(ctx)
+	if err != nil {
+		p.discard(nil)
+		return nil, err
+	}
+	p.take(sender)
+	return sender, nil
+}
+
+// take 记录 sender 已从连接池取出，Put 只接受这样的连接。
+func (p *SMTPSenderPool) take(sender *SMTPSender) {
+	p.mtx.Lock()
+	sender.pool = p
+	p.mtx.Unlock()
+}
+
+// Put 将连接放回连接池。连接已断开、RSET 失败、超过 MaxLifetime 或 MaxMessages，
+// 或连接池已关闭时，关闭该连接。不是从本连接池取出或已经放回的连接被忽略。
+func (p *SMTPSenderPool) Put(sender *SMTPSender) {
+	if sender == nil {
+		return
+	}
+	p.mtx.Lock()
+	if sender.pool != p {
+		p.mtx.Unlock()
+		return
+	}
+	sender.pool = nil
+	p.mtx.Unlock()
+
+	if sender.client == nil || p.expired(sender, time.Now()) || p.isClosed() {
+		p.discard(sender)
+		return
+	}
+	if err := sender.Reset(); err != nil {
+		utils.Logger.Debugln(utils.LogPrefix, "RSET failed, close the connection:", err)
+		p.discard(sender)
+		return
+	}
+
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		p.discard(sender)
+		return
+	}
+	sender.lastUsed = time.Now()
+	p.idle = append(p.idle, sender)
+	p.mtx.Unlock()
+	p.release()
+}
+
+// discard 关闭正在使用的连接 sender（可为 nil），并释放其占用的位置。
+func (p *SMTPSenderPool) discard(sender *SMTPSender) {
+	if sender != nil && sender.client != nil {
+		sender.Quit()
+	}
+	p.mtx.Lock()
+	p.open--
+	p.mtx.Unlock()
+	p.release()
+}
+
+func (p *SMTPSenderPool) release() {
+	<-p.sem
+	p.inUse.Done()
+}
+
+// expired 报告连接是否超过 MaxLifetime、MaxMessages 或 MaxIdleTime。
+func (p *SMTPSenderPool) expired(sender *SMTPSender, now time.Time) bool {
+	c := p.config
+	return (c.MaxLifetime > 0 && now.Sub(sender.createdAt) >= c.MaxLifetime) ||
+		(c.MaxMessages > 0 && sender.messages >= c.MaxMessages) ||
+		(c.MaxIdleTime > 0 && !sender.lastUsed.IsZero() && now.Sub(sender.lastUsed) >= c.MaxIdleTime)
+}
+
+func (p *SMTPSenderPool) isClosed() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.closed
+}
+
+// healthCheck 每隔 HealthCheckInterval 检查一次空闲连接，直到连接池关闭。
+func (p *SMTPSenderPool) healthCheck() {
+	ticker := time.NewTicker(p.config.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.checkIdle()
+		}
+	}
+}
+
+// checkIdle 逐个检查空闲连接：与 Get 一样占用一个位置，取出空闲最久的连接检查，
+// 正常的连接放回，其余连接关闭。检查期间其他空闲连接仍可取用，连接数不超过 MaxSize。
+func (p *SMTPSenderPool) checkIdle() {
+	p.mtx.Lock()
+	n := len(p.idle)
+	p.mtx.Unlock()
+
+	for range n {
+		select {
+		case p.sem <- struct{}{}:
+		default:
+			return // 连接都在使用中，下次再检查
+		}
+		p.mtx.Lock()
+		if p.closed || len(p.idle) == 0 {
+			p.mtx.Unlock()
+			<-p.sem
+			return
+		}
+		sender := p.idle[0]
+		p.idle = p.idle[1:]
+		p.inUse.Add(1)
+		p.mtx.Unlock()
+
+		if p.expired(sender, time.Now()) {
+			p.discard(sender)
+			continue
+		}
+		if err := sender.ping(context.Background()); err != nil {
+			utils.Logger.Debugln(utils.LogPrefix, "idle connection is broken, close it:", err)
+			if sender.client != nil {
+				sender.abort()
+			}
+			p.discard(sender)
+			continue
+		}
+
+		p.mtx.Lock()
+		if p.closed {
+			p.mtx.Unlock()
+			p.discard(sender)
+			return
+		}
+		p.idle = append(p.idle, sender) // 不更新 lastUsed，MaxIdleTime 仍从放回时算起
+		p.mtx.Unlock()
+		p.release()
+	}
+}
+
+// Stats 返回连接池的统计信息。
+func (p *SMTPSenderPool) Stats() PoolStats {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return PoolStats{Open: p.open, Idle: len(p.idle), InUse: len(p.sem)}
+}
+
+// Close 关闭连接池：关闭所有空闲连接，并等待正在使用的连接放回后将其关闭。
+// 之后的 Get 返回 ErrPoolClosed。
+func (p *SMTPSenderPool) Close() {
+	p.CloseContext(context.Background())
+}
+
+// CloseContext 与 Close 相同，ctx 结束时不再等待正在使用的连接，返回 ctx 的错误；
+// 这些连接放回时仍会被关闭。
+func (p *SMTPSenderPool) CloseContext(ctx context.Context) error {
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	idle := p.idle
+	p.idle = nil
+	p.open -= len(idle)
+	p.mtx.Unlock()
+
+	for _, sender := range idle {
+		sender.Quit()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		p.inUse.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reset 发送 RSET 命令，放弃当前的邮件事务，连接可继续发送下一封邮件。
+func (s *SMTPSender) Reset() error {
+	if s.client == nil {
+		return ErrNotConnected
+	}
+	s.setDeadline(context.Background(), s.smtp.CommandTimeout)
+	defer s.clearDeadline()
+	if s.smtp.LMTP {
+		_, _, err := s.cmd(250, "RSET")
+		return err
+	}
+	return newSMTPError("RSET", s.client.Reset())
+}
+
+// ping 发送 NOOP 命令检查连接，受 ctx 与 CommandTimeout 约束；
+// CommandTimeout 为 0 时最多等待 defaultPingTimeout。
+func (s *SMTPSender) ping(ctx context.Context) error {
+	timeout := s.smtp.CommandTimeout
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+	if err := s.setDeadline(ctx, timeout); err != nil {
+		return err
+	}
+	defer s.clearDeadline()
+	stop := s.watch(ctx)
+	defer stop()
+	return s.ctxErr(ctx, s.Noop())
+}
@@ -0,0 +1,79 @@
+package smtp
+
+import (
+	"errors"
+	"net/textproto"
+)
+
+// RecipientStatus 单个收件人的投递状态：服务器对该收件人的响应码与响应文本。
+type RecipientStatus struct {
+	Address      string `json:"address"`
+	Code         int    `json:"code"`
+	EnhancedCode string `json:"enhanced_code,omitempty"` // RFC 3463 增强状态码，服务器未提供时为空
+	Message      string `json:"message"`
+}
+
+// SendResult 一次发送中每个收件人的结果。
+type SendResult struct {
+	Accepted   []RecipientStatus `json:"accepted"`    // 服务器已接收
+	TempFailed []RecipientStatus `json:"temp_failed"` // 暂时失败（4xx），稍后可重试
+	PermFailed []RecipientStatus `json:"perm_failed"` // 永久失败（5xx）
+}
+
+// Failed 返回全部失败的收件人，包括暂时失败与永久失败。
+func (r *SendResult) Failed() []RecipientStatus {
+	return append(append([]RecipientStatus(nil), r.TempFailed...), r.PermFailed...)
+}
+
+// add 按响应码将收件人归入对应的列表。
+func (r *SendResult) add(st RecipientStatus) {
+	switch {
+	case st.Code >= 200 && st.Code < 400:
+		r.Accepted = append(r.Accepted, st)
+	case st.Code >= 400 && st.Code < 500:
+		r.TempFailed = append(r.TempFailed, st)
+	default:
+		r.PermFailed = append(r.PermFailed, st)
+	}
+}
+
+// reply 一条命令的响应。
+type reply struct {
+	code int
+	msg  string
+	err  error
+}
+
+// readReply 读取命令 command 的一条响应。返回的 error 仅表示连接层面的错误（读取失败），
+// 服务器的错误响应以 *SMTPError 保存在 reply.err 中。
+func readReply(text *textproto.Conn, command string, expectCode int) (reply, error) {
+	code, msg, err := text.ReadResponse(expectCode)
+	var tpErr *textproto.Error
+	if err != nil && !errors.As(err, &tpErr) {
+		return reply{}, err
+	}
+	return reply{code: code, msg: msg, err: newSMTPError(command, err)}, nil
+}
+
+// newSendResult 根据每个 RCPT 命令的响应生成发送结果，to 与 replies 一一对应。
+func newSendResult(to []string, replies []reply) *SendResult {
+	r := &SendResult{}
+	for i, rp := range replies {
+		enhanced, msg := parseEnhancedCode(rp.msg)
+		r.add(RecipientStatus{Address: to[i], Code: rp.code, EnhancedCode: enhanced, Message: msg})
+	}
+	return r
+}
+
+// fail 邮件内容被服务器拒绝时，已接收的收件人同样视为失败，状态为服务器对邮件内容的响应。
+func (r *SendResult) fail(err error) {
+	var e *SMTPError
+	if !errors.As(err, &e) {
+		return
+	}
+	accepted := r.Accepted
+	r.Accepted = nil
+	for _, st := range accepted {
+		r.add(RecipientStatus{Address: st.Address, Code: e.Code, EnhancedCode: e.EnhancedCode, Message: e.Message})
+	}
+}
@@ -0,0 +1,220 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+const (
+	defaultRetryAttempts   = 3
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 5 * time.Minute
+	defaultRetryMultiplier = 2
+)
+
+// RetryPolicy 发送失败后的重试策略：可重试的错误（见 IsRetryable）按指数退避等待后重试，
+// 直到成功、遇到不可重试的错误、达到 MaxAttempts 或 MaxElapsed。
+//
+// 设置 SMTP.RetryPolicy 后，DialAndSend 系列方法按此策略重试每封邮件，连接断开时重新连接；
+// 也可以用 Do 重试任意操作。nil 表示不重试。
+type RetryPolicy struct {
+	// MaxAttempts 最多尝试的次数，包括第一次，默认 3。
+	MaxAttempts int
+	// InitialBackoff 第一次重试前的等待时间，默认 1 秒。
+	InitialBackoff time.Duration
+	// MaxBackoff 等待时间的上限，默认 5 分钟。
+	MaxBackoff time.Duration
+	// Multiplier 每次重试后等待时间的倍数，默认 2。
+	Multiplier float64
+	// Jitter 随机抖动的比例（0~1）：实际等待时间在 backoff×(1-Jitter) 与 backoff 之间随机选取，
+	// 避免大量客户端同时重试。0 表示不抖动。
+	Jitter float64
+	// MaxElapsed 从第一次尝试开始的总时间上限，下一次重试会超过此时间时不再重试。0 表示不限制。
+	MaxElapsed time.Duration
+	// Retryable 判断错误是否可以重试，nil 时使用 IsRetryable。
+	Retryable func(err error) bool
+	// OnAttempt 每次尝试结束后调用，包括成功的尝试。
+	OnAttempt func(a RetryAttempt)
+}
+
+// RetryAttempt 一次尝试的结果。
+type RetryAttempt struct {
+	// Attempt 第几次尝试，从 1 开始。
+	Attempt int
+	// Err 本次尝试的错误，nil 表示成功。
+	Err error
+	// Retry 是否还会重试。
+	Retry bool
+	// Delay 下一次尝试前的等待时间，Retry 为 false 时为 0。
+	Delay time.Duration
+	// Elapsed 从第一次尝试开始经过的时间。
+	Elapsed time.Duration
+}
+
+// NewRetryPolicy 创建一个重试策略：最多尝试 maxAttempts 次，等待时间从 1 秒开始翻倍，抖动比例 0.5。
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: maxAttempts, Jitter: 0.5}
+}
+
+// Do 按重试策略执行 fn，返回最后一次尝试的错误。
+// 等待重试时 ctx 结束，返回 ctx 的错误。p 为 nil 时只执行一次。
+func (p *RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p == nil {
+		return fn(ctx)
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+
+		a := RetryAttempt{Attempt: attempt, Err: err, Elapsed: time.Since(start)}
+		if ctx.Err() == nil && p.ShouldRetry(attempt, err) {
+			a.Delay = p.Backoff(attempt)
+			a.Retry = p.MaxElapsed <= 0 || a.Elapsed+a.Delay <= p.MaxElapsed
+		}
+		if !a.Retry {
+			a.Delay = 0
+		}
+		if p.OnAttempt != nil {
+			p.OnAttempt(a)
+		}
+		if !a.Retry {
+			if err != nil && attempt > 1 {
+				return fmt.Errorf("goemail: giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		timer := time.NewTimer(a.Delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Backoff 返回第 attempt 次尝试失败后、下一次尝试前的等待时间（含随机抖动），attempt 从 1 开始。
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	d := float64(p.initialBackoff())
+	for range attempt - 1 {
+		d *= p.multiplier()
+		if d >= float64(p.maxBackoff()) {
+			break
+		}
+	}
+	return p.jitter(min(time.Duration(d), p.maxBackoff()))
+}
+
+// ShouldRetry 报告第 attempt 次尝试的错误 err 是否还应重试：err 可重试且未达到 MaxAttempts。
+func (p *RetryPolicy) ShouldRetry(attempt int, err error) bool {
+	return err != nil && attempt < p.maxAttempts() && p.retryable(err)
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return defaultRetryAttempts
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) initialBackoff() time.Duration {
+	if p.InitialBackoff <= 0 {
+		return defaultRetryBackoff
+	}
+	return p.InitialBackoff
+}
+
+func (p *RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return defaultRetryMaxBackoff
+	}
+	return p.MaxBackoff
+}
+
+func (p *RetryPolicy) multiplier() float64 {
+	if p.Multiplier < 1 {
+		return defaultRetryMultiplier
+	}
+	return p.Multiplier
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+func (p *RetryPolicy) jitter(d time.Duration) time.Duration {
+	j := min(max(p.Jitter, 0), 1)
+	return d - time.Duration(j*rand.Float64()*float64(d))
+}
+
+// IsRetryable 报告 err 是否为暂时性的故障，稍后重试可能成功：
+// 服务器的暂时性错误响应（4xx，如灰名单的 450、451，或 421），网络错误与连接断开，
+// 以及 ErrRateLimited、ErrPoolTimeout。
+// 永久性错误响应（5xx）、配置错误、context 的错误，以及 *PartialDeliveryError 不可重试。
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var partial *PartialDeliveryError
+	if errors.As(err, &partial) {
+		return false // 已投递的收件人会收到重复的邮件
+	}
+	var e *SMTPError
+	if errors.As(err, &e) {
+		return e.Temporary()
+	}
+	if errors.Is(err, ErrConnectionLost) || errors.Is(err, ErrNotConnected) ||
+		errors.Is(err, ErrRateLimited) || errors.Is(err, ErrPoolTimeout) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+/* ####################################################################### */
+
+// dialAndSend 连接服务器并依次调用 send 发送 n 封邮件，每封邮件按 RetryPolicy 重试；
+// 发送失败后连接不可用时，下一次尝试重新连接。
+func (s *SMTP) dialAndSend(ctx context.Context, n int, send func(sender *SMTPSender, i int) error) error {
+	var sender *SMTPSender
+	defer func() {
+		if sender != nil {
+			sender.Quit()
+		}
+	}()
+
+	for i := range n {
+		err := s.RetryPolicy.Do(ctx, func(ctx context.Context) error {
+			if sender == nil {
+				var err error
+				if sender, err = s.DialContext(ctx); err != nil {
+					return err
+				}
+			}
+			err := send(sender, i)
+			if err != nil && (sender.client == nil || sender.Reset() != nil) {
+				// 连接已断开或会话状态未知，下一次尝试重新连接
+				if sender.client != nil {
+					sender.abort()
+				}
+				sender = nil
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// scramAuth is an smtp.Auth that implements the SCRAM authentication mechanisms.
+// scramAuth 实现 SCRAM 认证机制（RFC 5802、RFC 7677），密码不会以明文或可重放的形式发送，
+// 并且客户端会校验服务器的签名（双向认证）。
+//
+// 带 -PLUS 后缀的机制使用 TLS 通道绑定（RFC 5929、RFC 9266）：TLS 1.3 使用 tls-exporter，
+// 更早的版本使用 tls-unique，可以发现中间人攻击。
+//
+// 用户名中的 "=" 与 "," 会按 RFC 5802 转义，但不做 SASLprep 规范化。
+type scramAuth struct {
+	mechanism string
+	username  string
+	password  string
+	newHash   func() hash.Hash
+	plus      bool // 使用通道绑定
+
+	// 通道绑定，plus 为 true 时由 session 设置
+	cbType string
+	cbData []byte
+	cbErr  error
+
+	// 一次认证过程的状态
+	gs2Header       string
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+	verified        bool
+}
+
+// ScramSHA1Auth 返回 SCRAM-SHA-1 认证机制。
+func ScramSHA1Auth(username, password string) smtp.Auth {
+	return &scramAuth{mechanism: SMTPAuthScramSHA1, username: username, password: password, newHash: sha1.New}
+}
+
+// ScramSHA256Auth 返回 SCRAM-SHA-256 认证机制。
+func ScramSHA256Auth(username, password string) smtp.Auth {
+	return &scramAuth{mechanism: SMTPAuthScramSHA256, username: username, password: password, newHash: sha256.New}
+}
+
+// ScramSHA256PlusAuth 返回带 TLS 通道绑定的 SCRAM-SHA-256-PLUS 认证机制，只能在 TLS 连接上使用。
+func ScramSHA256PlusAuth(username, password string) smtp.Auth {
+	return &scramAuth{mechanism: SMTPAuthScramSHA256Plus, username: username, password: password, newHash: sha256.New, plus: true}
+}
+
+// session 返回用于一个连接的 scramAuth：认证过程有状态，且通道绑定数据取决于连接，
+// 因此不能在多个连接之间共享。cs 为 nil 表示非 TLS 连接。
+func (a *scramAuth) session(_ context.Context, cs *tls.ConnectionState) smtp.Auth {
+	s := &scramAuth{
+		mechanism: a.mechanism,
+		username:  a.username,
+		password:  a.password,
+		newHash:   a.newHash,
+		plus:      a.plus,
+	}
+	if s.plus {
+		s.cbType, s.cbData, s.cbErr = channelBinding(cs)
+	}
+	return s
+}
+
+// channelBinding 返回 TLS 连接的通道绑定类型与数据。
+func channelBinding(cs *tls.ConnectionState) (string, []byte, error) {
+	if cs == nil {
+		return "", nil, errors.New("goemail: SCRAM channel binding requires a TLS connection")
+	}
+	if cs.Version >= tls.VersionTLS13 {
+		data, err := cs.ExportKeyingMaterial("EXPORTER-Channel-Binding", nil, 32)
+		return "tls-exporter", data, err
+	}
+	if len(cs.TLSUnique) == 0 {
+		return "", nil, errors.New("goemail: tls-unique channel binding is not available on this connection")
+	}
+	return "tls-unique", cs.TLSUnique, nil
+}
+
+func (a *scramAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if a.plus {
+		if a.cbType == "" && a.cbErr == nil {
+			// 未经 SMTP.Dial 使用时，从 ServerInfo 无法得到 TLS 连接状态
+			a.cbErr = errors.New("goemail: SCRAM channel binding requires a TLS connection")
+		}
+		if a.cbErr != nil {
+			return "", nil, a.cbErr
+		}
+		a.gs2Header = "p=" + a.cbType + ",,"
+	} else {
+		a.gs2Header = "n,,"
+	}
+
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	a.clientNonce = base64.StdEncoding.EncodeToString(nonce)
+	a.clientFirstBare = "n=" + scramEscape(a.username) + ",r=" + a.clientNonce
+	a.serverSignature = nil
+	a.verified = false
+	return a.mechanism, []byte(a.gs2Header + a.clientFirstBare), nil
+}
+
+func (a *scramAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	switch {
+	case !more:
+		if !a.verified {
+			return nil, errors.New("goemail: SCRAM server signature was not verified")
+		}
+		return nil, nil
+	case a.serverSignature == nil:
+		return a.clientFinal(string(fromServer))
+	default:
+		if err := a.verify(string(fromServer)); err != nil {
+			return nil, err
+		}
+		return []byte{}, nil
+	}
+}
+
+// clientFinal 根据 server-first-message 计算 client-final-message。
+func (a *scramAuth) clientFinal(serverFirst string) ([]byte, error) {
+	attrs, err := scramAttributes(serverFirst)
+	if err != nil {
+		return nil, err
+	}
+	nonce, salt64, iter := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, a.clientNonce) || len(nonce) == len(a.clientNonce) {
+		return nil, errors.New("goemail: SCRAM server nonce is invalid")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("goemail: SCRAM salt is invalid")
+	}
+	iterations, err := strconv.Atoi(iter)
+	if err != nil || iterations <= 0 {
+		return nil, fmt.Errorf("goemail: SCRAM iteration count %q is invalid", iter)
+	}
+
+	salted, err := pbkdf2.Key(a.newHash, a.password, salt, iterations, a.newHash().Size())
+	if err != nil {
+		return nil, err
+	}
+	clientKey := a.hmac(salted, "Client Key")
+	h := a.newHash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	cbind := append([]byte(a.gs2Header), a.cbData...)
+	clientFinalNoProof := "c=" + base64.StdEncoding.EncodeToString(cbind) + ",r=" + nonce
+	authMessage := a.clientFirstBare + "," + serverFirst + "," + clientFinalNoProof
+
+	proof := a.hmac(storedKey, authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	a.serverSignature = a.hmac(a.hmac(salted, "Server Key"), authMessage)
+	return []byte(clientFinalNoProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verify 校验 server-final-message 中的服务器签名。
+func (a *scramAuth) verify(serverFinal string) error {
+	attrs, err := scramAttributes(serverFinal)
+	if err != nil {
+		return err
+	}
+	if e, ok := attrs["e"]; ok {
+		return fmt.Errorf("goemail: SCRAM authentication failed: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || subtle.ConstantTimeCompare(signature, a.serverSignature) != 1 {
+		return errors.New("goemail: SCRAM server signature mismatch")
+	}
+	a.verified = true
+	return nil
+}
+
+func (a *scramAuth) hmac(key []byte, msg string) []byte {
+	mac := hmac.New(a.newHash, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
+
+// scramAttributes 解析 "a=1,b=2" 形式的 SCRAM 消息。
+func scramAttributes(msg string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for _, field := range strings.Split(msg, ",") {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok || len(k) != 1 {
+			return nil, fmt.Errorf("goemail: malformed SCRAM message %q", msg)
+		}
+		if k == "m" {
+			return nil, errors.New("goemail: unsupported SCRAM extension")
+		}
+		attrs[k] = v
+	}
+	return attrs, nil
+}
+
+// scramEscape 按 RFC 5802 第 5.1 节转义用户名中的 "=" 与 ","。
+func scramEscape(s string) string {
+	if !strings.ContainsAny(s, "=,") {
+		return s
+	}
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '=':
+			b.WriteString("=3D")
+		case ',':
+			b.WriteString("=2C")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -1,11 +1,12 @@
 package smtp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/smtp"
-	"sync"
 	"time"
 )
 
@@ -14,8 +15,26 @@ type SMTPSender struct {
 	// client  *smtp.Client
 	client *Client
 	smtp   *SMTP
+	// conn 是 client 底层的网络连接，用于设置读写截止时间。
+	// 使用 NewSMTPSender1 创建时为 nil，此时不支持超时与取消。
+	conn net.Conn
+	// localName 本连接 EHLO 使用的主机名，见 SMTP.SourceAddrs。
+	localName string
+	// lmtpExt LMTP 模式下，LHLO 响应中服务器支持的扩展。
+	lmtpExt map[string]string
+	// interceptors 只作用于本连接的拦截器，见 Use。
+	interceptors []Interceptor
+
+	// 以下字段由 SMTPSenderPool 使用
+	pool      *SMTPSenderPool // 取出该连接、尚未放回的连接池
+	createdAt time.Time       // 连接建立的时间
+	lastUsed  time.Time       // 最近一次放回连接池的时间
+	messages  int             // 已开始的邮件事务数
 }
 
+// aLongTimeAgo 是一个早已过去的时间点，将其设为连接的截止时间可立即中断阻塞的读写。
+var aLongTimeAgo = time.Unix(1, 0)
+
 func NewSMTPSender(smtp *SMTP) *SMTPSender {
 	return &SMTPSender{smtp: smtp}
 }
@@ -23,21 +42,102 @@ func NewSMTPSender1(client *Client, smtp *SMTP) *SMTPSender {
 	return &SMTPSender{client: client, smtp: smtp}
 }
 
-// Quit 关闭连接
+// Quit 发送 QUIT 命令并关闭连接；QUIT 失败（如服务器已断开连接）时也关闭连接，并返回该错误。
 func (s *SMTPSender) Quit() error {
-	if err := s.client.Quit(); err != nil {
-		return err
+	if s.client == nil {
+		return ErrNotConnected
+	}
+	defer func() {
+		s.client.Close()
+		s.client = nil
+		s.conn = nil
+	}()
+	s.setDeadline(context.Background(), s.smtp.CommandTimeout)
+	if s.smtp.LMTP {
+		if _, _, err := s.cmd(221, "QUIT"); err != nil {
+			return err
+		}
+	} else if err := s.client.Quit(); err != nil {
+		return newSMTPError("QUIT", err)
 	}
-	s.client = nil
 	return nil
 }
 
+// watch 在 ctx 被取消时，立即中断连接上阻塞的读写。
+// 返回的 stop 函数用于停止监听，应在操作结束后调用。
+func (s *SMTPSender) watch(ctx context.Context) (stop func() bool) {
+	conn := s.conn
+	if conn == nil || ctx.Done() == nil {
+		return func() bool { return true }
+	}
+	return context.AfterFunc(ctx, func() {
+		conn.SetDeadline(aLongTimeAgo)
+	})
+}
+
+// setDeadline 为接下来的一个阶段设置连接的读写截止时间，
+// 取 now+timeout 与 ctx 截止时间中较早的一个；timeout <= 0 表示只受 ctx 约束。
+func (s *SMTPSender) setDeadline(ctx context.Context, timeout time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.conn == nil {
+		return nil
+	}
+
+	var t time.Time
+	if timeout > 0 {
+		t = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+	return s.conn.SetDeadline(t)
+}
+
+// clearDeadline 清除连接的读写截止时间，避免影响之后的空闲连接。
+func (s *SMTPSender) clearDeadline() {
+	if s.conn != nil {
+		s.conn.SetDeadline(time.Time{})
+	}
+}
+
+// ctxErr 若 ctx 已结束，则返回 ctx 的错误并关闭连接（此时 SMTP 会话状态已不可知）；
+// 否则原样返回 err。
+func (s *SMTPSender) ctxErr(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	ctxErr := ctx.Err()
+	if ctxErr == nil {
+		// 连接的截止时间取自 ctx，可能在 ctx 结束之前先触发
+		var netErr net.Error
+		if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) && errors.As(err, &netErr) && netErr.Timeout() {
+			ctxErr = context.DeadlineExceeded
+		}
+	}
+	if ctxErr == nil {
+		return err
+	}
+	if s.client != nil {
+		s.abort()
+	}
+	return ctxErr
+}
+
 // Noop 发送 NOOP 命令(No Operation, 无操作命令)，用于测试连接是否正常。
 //
 // Return
 //   - {error} 错误信息。nil 表示 NOOP 命令成功，连接正常。
 func (s SMTPSender) Noop() error {
-	return s.client.Noop()
+	if s.client == nil {
+		return ErrNotConnected
+	}
+	if s.smtp.LMTP {
+		_, _, err := s.cmd(250, "NOOP")
+		return err
+	}
+	return newSMTPError("NOOP", s.client.Noop())
 }
 
 func (s *SMTPSender) Client() *smtp.Client {
@@ -53,15 +153,23 @@ func (s *SMTPSender) IsConnected() bool {
 
 // Dial 连接SMTP server。未连接 或 连接断开，才会重新连接SMTP服务器。
 func (s *SMTPSender) Dial() error {
+	return s.DialContext(context.Background())
+}
+
+// DialContext 与 Dial 相同，ctx 用于取消连接过程。
+func (s *SMTPSender) DialContext(ctx context.Context) error {
 	if s.IsConnected() {
 		return nil
 	}
 
-	sender, err := s.smtp.Dial()
+	sender, err := s.smtp.DialContext(ctx)
 	if err != nil {
 		return err
 	}
-	s.client = sender.client
+	interceptors, pool := s.interceptors, s.pool
+	*s = *sender
+	// 重新连接后保留本连接的拦截器，以及取出该连接的连接池
+	s.interceptors, s.pool = interceptors, pool
 	return nil
 }
 
@@ -72,33 +180,32 @@ func (s *SMTPSender) Dial() error {
 //     false 从消息中获取发件人。
 //   - msgs 邮件内容，*Message 列表。
 func (s *SMTPSender) Send(whereFrom bool, msgs ...*Message) error {
+	return s.SendContext(context.Background(), whereFrom, msgs...)
+}
+
+// SendContext 与 Send 相同，ctx 被取消或超过截止时间时，中断正在进行的 SMTP 会话。
+func (s *SMTPSender) SendContext(ctx context.Context, whereFrom bool, msgs ...*Message) error {
 	for i, m := range msgs {
-		if err := s.send(whereFrom, m); err != nil {
-			return fmt.Errorf("goemail: could not send email %d: %v", i+1, err)
+		if err := s.send(ctx, whereFrom, m); err != nil {
+			return fmt.Errorf("goemail: could not send email %d: %w", i+1, err)
 		}
 	}
 	return nil
 }
 
-func (s *SMTPSender) send(whereFrom bool, m *Message) error {
-	var from string
-
-	switch whereFrom {
-	case true:
-		from = s.smtp.from // 使用配置中的发件人
-	case false:
-		email, err := m.getFrom() // 从消息中获取发件人，“服务商”可能会拒绝此方式
-		from = email
-		if err != nil {
-			return fmt.Errorf("获取发件人失败: %v", err)
-		}
-	}
-
-	to, err := m.getRecipients() // 获取收件人
+func (s *SMTPSender) send(ctx context.Context, whereFrom bool, m *Message) error {
+	env, err := s.envelope(whereFrom, m)
 	if err != nil {
-		return fmt.Errorf("获取收件人失败: %v", err)
+		return err
 	}
-	return s.SendEmail(from, to, m)
+	return s.SendEnvelope(ctx, env, m)
+}
+
+// envelope 根据邮件 m 生成信封。
+//
+// whereFrom 为 true 使用 smtp字段 中的发件人（smtp.from），false 从消息中获取发件人。
+func (s *SMTPSender) envelope(whereFrom bool, m *Message) (*Envelope, error) {
+	return s.smtp.envelope(whereFrom, m)
 }
 
 // SendEmail 发送邮件，可以将 msg 发送给多个收件人（群发）。
@@ -108,32 +215,46 @@ func (s *SMTPSender) send(whereFrom bool, m *Message) error {
 //   - to {[]string} 收件人邮箱切片
 //   - msg {io.WriterTo} 邮件内容，需实现 io.WriterTo 接口
 func (s *SMTPSender) SendEmail(from string, to []string, msg io.WriterTo) error {
-	if s.client == nil {
-		return errors.New("SMTP 客户端未连接")
-	} else if s.Noop() != nil {
-		return errors.New("NOOP 命令失败, 连接可能已断开")
-	}
+	return s.SendEmailContext(context.Background(), from, to, msg)
+}
 
-	// 设置发件人和收件人
-	if err := s.client.Mail(from); err != nil {
-		return err
-	}
-	for _, addr := range to {
-		if err := s.client.Rcpt(addr); err != nil {
-			return err
-		}
-	}
+// SendEmailContext 与 SendEmail 相同，ctx 作用于 MAIL、RCPT、DATA 以及邮件内容的写入。
+func (s *SMTPSender) SendEmailContext(ctx context.Context, from string, to []string, msg io.WriterTo) error {
+	return s.SendEnvelope(ctx, &Envelope{From: from, To: to}, msg)
+}
 
-	// 发送邮件内容
-	w, err := s.client.Data()
-	if err != nil {
-		return err
-	}
+// SendEnvelope 按信封 env 发送邮件 msg，可通过 env.DSN 为本次发送设置投递状态通知参数。
+//
+// Args
+//   - ctx 作用于 MAIL、RCPT、DATA 以及邮件内容的写入
+//   - env {*Envelope} 信封：发件人、收件人及 DSN 参数
+//   - msg {io.WriterTo} 邮件内容，需实现 io.WriterTo 接口
+func (s *SMTPSender) SendEnvelope(ctx context.Context, env *Envelope, msg io.WriterTo) error {
+	_, err := s.intercept(ctx, env, msg, false)
+	return err
+}
 
-	if _, err := msg.WriteTo(w); err != nil {
-		return err
+// SendPartial 按信封 env 发送邮件 msg，并返回每个收件人的结果。
+//
+// 与 SendEnvelope 不同，个别收件人被拒绝（如 Cc 列表中的错误地址）不会中断发送：
+// 只要有一个收件人被接收，就继续发送邮件内容。
+// 所有收件人都被拒绝时，返回第一个收件人的错误；SendResult 中仍包含每个收件人的响应。
+func (s *SMTPSender) SendPartial(ctx context.Context, env *Envelope, msg io.WriterTo) (*SendResult, error) {
+	return s.intercept(ctx, env, msg, true)
+}
+
+// transact 在 ctx 的约束下完成一次邮件事务，见 sendMail。
+func (s *SMTPSender) transact(ctx context.Context, env *Envelope, msg io.WriterTo, partial bool) (*SendResult, error) {
+	if s.client == nil {
+		return nil, ErrNotConnected
 	}
-	return w.Close()
+
+	stop := s.watch(ctx)
+	defer stop()
+
+	result, err := s.sendMail(ctx, env, msg, partial)
+	s.clearDeadline()
+	return result, s.ctxErr(ctx, err)
 }
 
 // Send1 发送邮件，使用 smtp.from 作为发件人
@@ -142,7 +263,12 @@ func (s *SMTPSender) SendEmail(from string, to []string, msg io.WriterTo) error
 //   - to: 收件人列表/切片
 //   - msg {[]byte}: 邮件内容
 func (s *SMTPSender) Send1(to []string, msg []byte) error {
-	return s.sendEmailBytes(s.smtp.from, to, msg)
+	return s.Send1Context(context.Background(), to, msg)
+}
+
+// Send1Context 与 Send1 相同，ctx 作用于整个邮件事务。
+func (s *SMTPSender) Send1Context(ctx context.Context, to []string, msg []byte) error {
+	return s.sendEmailBytes(ctx, s.smtp.from, to, msg)
 }
 
 // Send2 发送邮件，指定发件人邮箱
@@ -152,165 +278,18 @@ func (s *SMTPSender) Send1(to []string, msg []byte) error {
 //   - to: 收件人列表
 //   - msg {[]byte}: 邮件内容
 func (s *SMTPSender) Send2(from string, to []string, msg []byte) error {
-	return s.sendEmailBytes(from, to, msg)
+	return s.Send2Context(context.Background(), from, to, msg)
+}
+
+// Send2Context 与 Send2 相同，ctx 作用于整个邮件事务。
+func (s *SMTPSender) Send2Context(ctx context.Context, from string, to []string, msg []byte) error {
+	return s.sendEmailBytes(ctx, from, to, msg)
 }
 
 // 发送邮件
 // from: 发件人
 // to: 收件人列表
 // msg {[]byte}: 邮件内容
-func (s *SMTPSender) sendEmailBytes(from string, to []string, msg []byte) error {
-	if s.client == nil {
-		return errors.New("SMTP 客户端未连接")
-	} else if s.Noop() != nil {
-		return errors.New("NOOP 命令失败, 连接可能已断开")
-	}
-
-	// 设置发件人和收件人
-	if err := s.client.Mail(from); err != nil {
-		return err
-	}
-	for _, addr := range to {
-		if err := s.client.Rcpt(addr); err != nil {
-			return err
-		}
-	}
-
-	// 发送邮件内容
-	w, err := s.client.Data()
-	if err != nil {
-		return err
-	}
-
-	if _, err := w.Write(msg); err != nil {
-		return err
-	}
-
-	return w.Close()
-}
-
-/* ####################################################################### */
-
-// SMTPSenderPool 是一个 SMTP 发送池。
-// 它维护一个连接池, 每个连接都是一个 Sender 实例。
-// 每个连接都有一个 SMTP 客户端, 用于发送邮件。
-type SMTPSenderPool struct {
-	senders chan *SMTPSender
-	config  *SMTPConfig
-	maxSize int
-	created int // 当前已创建的连接数
-	mtx     sync.Mutex
-}
-
-func NewSMTPSenderPool(poolSize int, config *SMTPConfig) *SMTPSenderPool {
-	pool := &SMTPSenderPool{
-		senders: make(chan *SMTPSender, poolSize),
-		config:  config,
-		maxSize: poolSize,
-	}
-
-	// 预初始化连接
-	smtp := NewSMTP(
-		config.Host, config.Port, config.Username, config.Password, config.From,
-	)
-	for range poolSize {
-		sender := NewSMTPSender(smtp)
-		if sender.Dial() == nil {
-			pool.senders <- sender
-			pool.created++
-		}
-	}
-
-	return pool
-}
-
-func (p *SMTPSenderPool) Get() (*SMTPSender, error) {
-	select {
-	case sender := <-p.senders:
-		if sender.IsConnected() {
-			return sender, nil
-		} else {
-			sender.Quit()
-			return p.replaceSender()
-		}
-	default:
-		// 池为空，检查是否还可以创建新连接
-		p.mtx.Lock()
-		defer p.mtx.Unlock()
-		if p.created < p.maxSize {
-			return p.createNewSender()
-		}
-
-		// 等待可用的连接
-		select {
-		case sender := <-p.senders:
-			return sender, nil
-		case <-time.After(30 * time.Second): // 超时
-			return nil, errors.New("no available sender in pool")
-		}
-	}
-}
-
-func (p *SMTPSenderPool) Put(sender *SMTPSender) {
-	// 检查连接是否仍然有效
-	if !sender.IsConnected() {
-		sender.Quit()
-		p.mtx.Lock()
-		p.created--
-		p.mtx.Unlock()
-		return
-	}
-
-	select {
-	case p.senders <- sender:
-		// 成功放回池中
-	default:
-		// 池已满，关闭连接
-		sender.Quit()
-		p.mtx.Lock()
-		p.created--
-		p.mtx.Unlock()
-	}
-}
-
-func (p *SMTPSenderPool) replaceSender() (*SMTPSender, error) {
-	p.mtx.Lock()
-	defer p.mtx.Unlock()
-
-	sender, err := p.create()
-	if err != nil {
-		p.created--
-		return nil, err
-	}
-
-	return sender, nil
-}
-
-func (p *SMTPSenderPool) createNewSender() (*SMTPSender, error) {
-	sender, err := p.create()
-	if err != nil {
-		return nil, err
-	}
-
-	p.created++
-	return sender, nil
-}
-
-func (p *SMTPSenderPool) create() (*SMTPSender, error) {
-	smtp := NewSMTP(
-		p.config.Host, p.config.Port, p.config.Username, p.config.Password, p.config.From,
-	)
-	sender := NewSMTPSender(smtp)
-	if err := sender.Dial(); err != nil {
-		return nil, err
-	}
-	return sender, nil
-}
-
-// Close 关闭连接池中的所有连接
-func (p *SMTPSenderPool) Close() {
-	close(p.senders)
-	for sender := range p.senders {
-		sender.Quit()
-	}
+func (s *SMTPSender) sendEmailBytes(ctx context.Context, from string, to []string, msg []byte) error {
+	return s.SendEmailContext(ctx, from, to, rawMessage(msg))
 }
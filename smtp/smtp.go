@@ -1,10 +1,11 @@
 package smtp
 
 import (
+	"context"
 	"crypto/tls"
-	"net"
+	"fmt"
 	"net/smtp"
-	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/JiuYu77/go-email/utils"
@@ -22,7 +23,7 @@ type SMTP struct {
 	// Password is the password to use to authenticate to the SMTP server.
 	password string // 不同认证机制使用不同 密码、token
 	// auth represents the authentication mechanism used to authenticate to the
-	// SMTP server.
+	// SMTP server. 由 SetAuth 设置，nil 时每次连接按 AuthMechanisms 选择。
 	auth smtp.Auth
 	// from email is usually the same as username.
 	from string
@@ -42,11 +43,85 @@ type SMTP struct {
 	// tlsConfig represents the TLS configuration used for the TLS (when the
 	// STARTTLS extension is used) or SSL connection.
 	TLSConfig *tls.Config
+	// TLSPolicy SSL 为 false 时是否使用 STARTTLS，默认 TLSOpportunistic。
+	// 需要确保连接加密时设置为 TLSMandatory。
+	TLSPolicy TLSPolicy
+	// MinTLSVersion 最低 TLS 版本，如 tls.VersionTLS12，0 表示使用 TLSConfig 或 crypto/tls 的默认值。
+	MinTLSVersion uint16
+	// TLSFingerprints 服务器证书的 SHA-256 指纹（十六进制，可用 ":" 分隔），
+	// 不为空时服务器证书必须与其中之一相同，否则返回 ErrFingerprintMismatch。
+	// 证书链仍会照常校验；使用自签名证书时，可同时设置 TLSConfig.InsecureSkipVerify，只校验指纹。
+	TLSFingerprints []string
+	// AllowInsecureAuth 为 true 时允许在未加密的连接上认证，
+	// 默认拒绝，返回 ErrInsecureAuth，避免明文发送密码或令牌。
+	AllowInsecureAuth bool
 	// LocalName is the hostname sent to the SMTP server with the HELO command.
 	// By default, "localhost" is sent.
 	LocalName string // 本地主机名
+
+	// AuthMechanisms 按优先顺序排列的认证机制，如 []SMTPAuthType{SMTPAuthScramSHA256, SMTPAuthPlain}。
+	// 连接时使用其中第一个服务器支持的机制，都不支持时返回 ErrNoAuthMechanism；
+	// 只列出一个机制即可强制使用该机制。
+	//
+	// 为空时按默认顺序选择：SCRAM 优先，TLS 连接上 PLAIN 优先于 CRAM-MD5。
+	// 使用 SetAuth 设置了 smtp.Auth 时忽略此字段。
+	AuthMechanisms []SMTPAuthType
+	// TokenSource OAuth 2.0 访问令牌的来源，用于 XOAUTH2 与 OAUTHBEARER 认证机制，
+	// 每次连接都会重新获取令牌，令牌过期后无需重新创建 SMTP。
+	// 设置后 password 不再作为令牌使用；未设置 AuthMechanisms 时优先使用 OAUTHBEARER，其次 XOAUTH2。
+	TokenSource TokenSource
+
+	// SourceAddrs 连接使用的本地地址，每个连接按轮询顺序使用其中一个，
+	// 并以该地址的 LocalName 作为 EHLO 主机名；只有一个地址时即绑定该地址。
+	// 为空时由系统选择。使用代理等自定义 Dialer 时忽略（*net.Dialer 除外）。
+	SourceAddrs []SourceAddr
+	// IPVersion 连接使用的 IP 协议版本，默认 IPAny。
+	IPVersion  IPVersion
+	sourceNext *atomic.Uint32 // SourceAddrs 的轮询计数，复制 SMTP 后仍共享
+
+	// LMTP 为 true 时使用 LMTP 协议（RFC 2033）代替 SMTP，用于把邮件交给本机的 Dovecot、Postfix 等。
+	// 以 LHLO 代替 EHLO，不使用 STARTTLS 与 AUTH（username 须为空）；
+	// 每个收件人有各自的投递结果，可通过 SMTPSender.SendPartial 取得。
+	// 通常与 UnixSocket 一起使用。
+	LMTP bool
+
+	// Dialer 建立网络连接使用的 Dialer，如 SOCKS5Dialer、HTTPProxyDialer，nil 时直接连接。
+	Dialer Dialer
+	// UnixSocket 本机 MTA 的 Unix 域套接字路径，如 "/var/run/smtp.sock"。
+	// 不为空时连接该套接字而不是 host:port，host 仍用于 TLS 与认证。
+	UnixSocket string
+
+	// DialTimeout 建立连接的超时时间，包括 TCP 连接与隐式 TLS 握手（SSL 为 true 时）。
+	// 默认 10 秒。
+	DialTimeout time.Duration
+	// CommandTimeout 单条 SMTP 命令的超时时间，包括等待服务器问候、EHLO、STARTTLS、
+	// AUTH、MAIL、RCPT 等命令。0 表示不限制（仍受 context 的截止时间约束）。
+	CommandTimeout time.Duration
+	// DataTimeout 发送邮件内容的超时时间，从 DATA 命令开始，到服务器确认接收邮件为止。
+	// 0 表示不限制（仍受 context 的截止时间约束）。
+	DataTimeout time.Duration
+
+	// ChunkSize 服务器支持 CHUNKING 扩展时，每条 BDAT 命令发送的字节数。
+	// 默认 1 MiB。
+	ChunkSize int
+
+	// DSN 投递状态通知参数（RFC 3461），用于 DialAndSend 等所有经此 SMTP 发送的邮件；
+	// 单次发送可通过 Envelope.DSN 覆盖。服务器不支持 DSN 扩展时忽略。
+	DSN *DSN
+
+	// Throttle 发送速率限制，nil 表示不限制。多个 SMTP 可以共享同一个 Throttle。
+	Throttle *Throttle
+	// RetryPolicy DialAndSend 系列方法的重试策略，nil 表示不重试。
+	RetryPolicy *RetryPolicy
+	// Interceptors 发送拦截器，作用于经此 SMTP 建立的全部连接发送的邮件，见 Interceptor。
+	Interceptors []Interceptor
 }
 
+const (
+	defaultDialTimeout = 10 * time.Second
+	defaultChunkSize   = 1 << 20
+)
+
 // NewSMTP 创建一个新的 SMTP 客户端
 //
 // # Args
@@ -71,14 +146,36 @@ func NewSMTP(host string, port int, username string, password string, from strin
 		from:     from,
 		SSL:      port == 465, // SSL 或 TLS 协议 (实际只使用TLS协议)
 		// SSL: true 使用 TLS 连接, false 尝试使用 STARTTLS extension(扩展).
+		sourceNext: new(atomic.Uint32),
 	}
 }
 
-func (s *SMTP) tlsCfg() *tls.Config {
-	if s.TLSConfig == nil {
-		return &tls.Config{ServerName: s.host} // default
+// SetAuth 设置认证使用的 smtp.Auth，如 smtp.PlainAuth、ScramSHA256Auth 或自定义实现，
+// 设置后不再按 AuthMechanisms 选择认证机制；auth 为 nil 时恢复自动选择。
+func (s *SMTP) SetAuth(auth smtp.Auth) {
+	s.auth = auth
+}
+
+// relay 返回中继服务器的地址，用于 Throttle 的每个中继的限制。
+func (s *SMTP) relay() string {
+	if s.UnixSocket != "" {
+		return s.UnixSocket
+	}
+	return addr(s.host, s.port)
+}
+
+func (s *SMTP) dialTimeout() time.Duration {
+	if s.DialTimeout <= 0 {
+		return defaultDialTimeout
 	}
-	return s.TLSConfig
+	return s.DialTimeout
+}
+
+func (s *SMTP) chunkSize() int {
+	if s.ChunkSize <= 0 {
+		return defaultChunkSize
+	}
+	return s.ChunkSize
 }
 
 // Dial 连接SMTP服务器
@@ -88,75 +185,158 @@ func (s *SMTP) tlsCfg() *tls.Config {
 //	{*SMTPSender} 发送邮件的结构体指针
 //	{error} nil 表示成功
 func (s *SMTP) Dial() (*SMTPSender, error) {
+	return s.DialContext(context.Background())
+}
+
+// DialContext 连接SMTP服务器，ctx 被取消或超过截止时间时，立即中断连接过程。
+//
+// ctx 作用于整个连接过程：TCP 连接、TLS 握手、EHLO、STARTTLS、AUTH，
+// 各阶段同时受 DialTimeout、CommandTimeout 约束。
+//
+// # Returns
+//
+//	{*SMTPSender} 发送邮件的结构体指针
+//	{error} nil 表示成功
+func (s *SMTP) DialContext(ctx context.Context) (*SMTPSender, error) {
 	utils.Logger.Debugln(utils.LogPrefix, "SMTP Dial() start.")
-	conn, err := net.DialTimeout("tcp", addr(s.host, s.port), 10*time.Second)
+	conn, localName, err := s.dial(ctx)
 	if err != nil {
 		utils.Logger.Errorln(utils.LogPrefix, err)
 		return nil, err
 	}
 
+	sender := &SMTPSender{conn: conn, smtp: s, localName: localName, createdAt: time.Now()}
+	stop := sender.watch(ctx)
+	defer stop()
+
+	client, err := s.handshake(ctx, sender)
+	if err != nil {
+		err = sender.ctxErr(ctx, err)
+		conn.Close()
+		utils.Logger.Errorln(utils.LogPrefix, err)
+		return nil, err
+	}
+	sender.client = client
+	return sender, nil
+}
+
+// handshake 在已建立的连接上完成 TLS、EHLO、STARTTLS、AUTH，返回可用于发送邮件的客户端。
+func (s *SMTP) handshake(ctx context.Context, sender *SMTPSender) (*Client, error) {
+	conn := sender.conn
 	if s.SSL { // 465 端口使用隐式TLS加密, 587 端口也可能使用隐式TLS加密
-		conn = tls.Client(conn, s.tlsCfg())
+		if err := sender.setDeadline(ctx, s.dialTimeout()); err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, s.tlsCfg())
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
 	}
 
+	if err := sender.setDeadline(ctx, s.CommandTimeout); err != nil {
+		return nil, err
+	}
 	client, err := smtp.NewClient(conn, s.host)
 	if err != nil {
-		utils.Logger.Errorln(utils.LogPrefix, err)
-		return nil, err
+		return nil, newSMTPError("", err) // 服务器问候
+	}
+	if s.LMTP {
+		if err := s.lmtpHandshake(ctx, sender, client); err != nil {
+			return nil, err
+		}
+		return client, nil
 	}
 
-	if s.LocalName != "" {
-		if err := client.Hello(s.LocalName); err != nil {
-			utils.Logger.Errorln(utils.LogPrefix, err)
+	if sender.localName != "" {
+		if err := sender.setDeadline(ctx, s.CommandTimeout); err != nil {
 			return nil, err
 		}
+		if err := client.Hello(sender.localName); err != nil {
+			return nil, newSMTPError("EHLO", err)
+		}
 	}
 
-	if !s.SSL { // 非 SSL/TLS 连接, 端口号为 25、587, 尝试使用 STARTTLS 扩展
+	if !s.SSL && s.TLSPolicy != NoTLS { // 非 SSL/TLS 连接, 端口号为 25、587, 尝试使用 STARTTLS 扩展
+		if err := sender.setDeadline(ctx, s.CommandTimeout); err != nil {
+			return nil, err
+		}
 		if ok, _ := client.Extension("STARTTLS"); ok {
 			if err := client.StartTLS(s.tlsCfg()); err != nil {
-				client.Close()
-				utils.Logger.Errorln(utils.LogPrefix, err)
-				return nil, err
+				return nil, newSMTPError("STARTTLS", err)
 			}
+		} else if s.TLSPolicy == TLSMandatory {
+			return nil, ErrTLSRequired
 		}
 	}
 
 	// 认证机制 (authentication mechanism)
-	if s.auth == nil && s.username != "" {
-		if ok, auth := client.Extension("AUTH"); ok {
-			switch {
-			case strings.Contains(auth, "CRAM-MD5"):
-				s.auth = smtp.CRAMMD5Auth(s.username, s.password)
-				utils.Logger.Debugln(utils.LogPrefix, "CRAM-MD5 auth")
-			case strings.Contains(auth, "XOAUTH2") && !strings.Contains(auth, "PLAIN"):
-				s.auth = &xoauth2Auth{
-					username: s.username,
-					token:    s.password,
-				}
-				utils.Logger.Debugln(utils.LogPrefix, "XOAUTH2 auth")
-			case strings.Contains(auth, "LOGIN") && !strings.Contains(auth, "PLAIN"):
-				s.auth = &loginAuth{
-					username: s.username,
-					password: s.password,
-					host:     s.host,
-				}
-				utils.Logger.Debugln(utils.LogPrefix, "LOGIN auth")
-			default:
-				s.auth = smtp.PlainAuth("", s.username, s.password, s.host)
-				utils.Logger.Debugln(utils.LogPrefix, "Plain auth")
+	auth, err := s.authFor(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil { // 认证
+		if _, ok := client.TLSConnectionState(); !ok && !s.AllowInsecureAuth {
+			return nil, ErrInsecureAuth
+		}
+		if err := sender.setDeadline(ctx, s.CommandTimeout); err != nil {
+			return nil, err
+		}
+		if err := client.Auth(auth); err != nil {
+			err = newSMTPError("AUTH", err)
+			if ti, ok := s.TokenSource.(tokenInvalidator); ok && IsAuthFailure(err) {
+				ti.Invalidate() // 令牌可能已被吊销，下次连接时重新获取
 			}
+			return nil, err
 		}
 	}
 
-	if s.auth != nil { // 认证
-		if err = client.Auth(s.auth); err != nil {
-			client.Close()
-			utils.Logger.Errorln(utils.LogPrefix, err)
-			return nil, err
+	sender.clearDeadline()
+	return client, nil
+}
+
+// lmtpHandshake LMTP 模式下完成 LHLO。LMTP 不使用 STARTTLS 与 AUTH（隐式 TLS 仍可使用）。
+func (s *SMTP) lmtpHandshake(ctx context.Context, sender *SMTPSender, client *Client) error {
+	if s.auth != nil || s.username != "" || s.TokenSource != nil {
+		return ErrLMTPAuth
+	}
+	if !s.SSL && s.TLSPolicy == TLSMandatory {
+		return ErrTLSRequired
+	}
+
+	sender.client = client
+	if err := sender.setDeadline(ctx, s.CommandTimeout); err != nil {
+		return err
+	}
+	if err := sender.lhlo(sender.localName); err != nil {
+		return err
+	}
+	sender.clearDeadline()
+	return nil
+}
+
+// envelope 根据邮件 m 生成信封。
+//
+// whereFrom 为 true 使用配置中的发件人（s.from），false 从消息中获取发件人。
+func (s *SMTP) envelope(whereFrom bool, m *Message) (*Envelope, error) {
+	var from string
+
+	switch whereFrom {
+	case true:
+		from = s.from // 使用配置中的发件人
+	case false:
+		email, err := m.getFrom() // 从消息中获取发件人，“服务商”可能会拒绝此方式
+		from = email
+		if err != nil {
+			return nil, fmt.Errorf("获取发件人失败: %w", err)
 		}
 	}
-	return &SMTPSender{client: client, smtp: s}, nil
+
+	to, err := m.getRecipients() // 获取收件人
+	if err != nil {
+		return nil, fmt.Errorf("获取收件人失败: %w", err)
+	}
+	return &Envelope{From: from, To: to}, nil
 }
 
 // DialAndSend 发送邮件，可以一次发送多封邮件。
@@ -165,12 +345,20 @@ func (s *SMTP) Dial() (*SMTPSender, error) {
 //   - whereFrom 是否从消息中获取发件人, true 使用配置中的发件人, false 从消息中获取发件人
 //   - msgs 邮件内容，*Message 列表
 func (s *SMTP) DialAndSend(whereFrom bool, msgs ...*Message) error {
-	sender, err := s.Dial() // 每次都会重新连接 SMTP服务器
-	if err != nil {
-		return err
-	}
-	defer sender.Quit()
-	return sender.Send(whereFrom, msgs...)
+	return s.DialAndSendContext(context.Background(), whereFrom, msgs...)
+}
+
+// DialAndSendContext 与 DialAndSend 相同，ctx 作用于连接与发送的全过程。
+//
+// 设置了 RetryPolicy 时，每封邮件按策略重试，连接断开时重新连接。
+func (s *SMTP) DialAndSendContext(ctx context.Context, whereFrom bool, msgs ...*Message) error {
+	// 每次都会重新连接 SMTP服务器
+	return s.dialAndSend(ctx, len(msgs), func(sender *SMTPSender, i int) error {
+		if err := sender.send(ctx, whereFrom, msgs[i]); err != nil {
+			return fmt.Errorf("goemail: could not send email %d: %w", i+1, err)
+		}
+		return nil
+	})
 }
 
 // DialAndSend1 发送邮件，可以群发
@@ -180,12 +368,14 @@ func (s *SMTP) DialAndSend(whereFrom bool, msgs ...*Message) error {
 //	to {[]string} 收件人列表
 //	msg {[]byte} 邮件内容
 func (s *SMTP) DialAndSend1(to []string, msg []byte) error {
-	sender, err := s.Dial()
-	if err != nil {
-		return err
-	}
-	defer sender.Quit()
-	return sender.Send1(to, msg)
+	return s.DialAndSend1Context(context.Background(), to, msg)
+}
+
+// DialAndSend1Context 与 DialAndSend1 相同，ctx 作用于连接与发送的全过程。
+func (s *SMTP) DialAndSend1Context(ctx context.Context, to []string, msg []byte) error {
+	return s.dialAndSend(ctx, 1, func(sender *SMTPSender, _ int) error {
+		return sender.Send1Context(ctx, to, msg)
+	})
 }
 
 // DialAndSend2 发送邮件，可以群发
@@ -195,10 +385,12 @@ func (s *SMTP) DialAndSend1(to []string, msg []byte) error {
 //   - to  收件人列表
 //   - msg  邮件内容
 func (s *SMTP) DialAndSend2(from string, to []string, msg []byte) error {
-	sender, err := s.Dial()
-	if err != nil {
-		return err
-	}
-	defer sender.Quit()
-	return sender.Send2(from, to, msg)
+	return s.DialAndSend2Context(context.Background(), from, to, msg)
+}
+
+// DialAndSend2Context 与 DialAndSend2 相同，ctx 作用于连接与发送的全过程。
+func (s *SMTP) DialAndSend2Context(ctx context.Context, from string, to []string, msg []byte) error {
+	return s.dialAndSend(ctx, 1, func(sender *SMTPSender, _ int) error {
+		return sender.Send2Context(ctx, from, to, msg)
+	})
 }
@@ -0,0 +1,138 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/JiuYu77/go-email/utils"
+)
+
+// SourceAddr 连接 SMTP 服务器使用的本地（出口）地址。
+type SourceAddr struct {
+	// IP 绑定的本地 IP 地址，必须是本机的地址。
+	IP net.IP
+	// LocalName 使用该地址时 EHLO 命令发送的主机名，通常是该 IP 反向解析（PTR）的域名。
+	// 为空时使用 SMTP.LocalName。
+	LocalName string
+}
+
+// IPVersion 连接 SMTP 服务器使用的 IP 协议版本。
+type IPVersion int
+
+const (
+	// IPAny 由系统决定使用 IPv4 或 IPv6。默认值。
+	IPAny IPVersion = iota
+	// IPv4Only 只使用 IPv4。
+	IPv4Only
+	// IPv6Only 只使用 IPv6。
+	IPv6Only
+	// PreferIPv4 优先使用 IPv4，连接失败时再尝试 IPv6。
+	PreferIPv4
+	// PreferIPv6 优先使用 IPv6，连接失败时再尝试 IPv4。
+	PreferIPv6
+)
+
+// networks 返回按顺序尝试的网络类型。
+func (v IPVersion) networks() []string {
+	switch v {
+	case IPv4Only:
+		return []string{"tcp4"}
+	case IPv6Only:
+		return []string{"tcp6"}
+	case PreferIPv4:
+		return []string{"tcp4", "tcp6"}
+	case PreferIPv6:
+		return []string{"tcp6", "tcp4"}
+	}
+	return []string{"tcp"}
+}
+
+// matches 报告本地地址 ip 能否用于网络类型 network 的连接。
+func matches(network string, ip net.IP) bool {
+	switch network {
+	case "tcp4":
+		return ip.To4() != nil
+	case "tcp6":
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// nextSource 按轮询顺序返回下一个可用于 network 的 SourceAddr，没有则返回 nil。
+func (s *SMTP) nextSource(network string) *SourceAddr {
+	n := len(s.SourceAddrs)
+	for range n {
+		i := int(s.sourceNext.Add(1)-1) % n
+		if src := &s.SourceAddrs[i]; matches(network, src.IP) {
+			return src
+		}
+	}
+	return nil
+}
+
+// dialTCP 按 IPVersion 与 SourceAddrs 连接 address，返回连接及该连接 EHLO 使用的主机名。
+func (s *SMTP) dialTCP(ctx context.Context, address string) (net.Conn, string, error) {
+	var errs []error
+	for _, network := range s.IPVersion.networks() {
+		var src *SourceAddr
+		if len(s.SourceAddrs) > 0 {
+			if src = s.nextSource(network); src == nil {
+				errs = append(errs, fmt.Errorf("goemail: no source address for %s", network))
+				continue
+			}
+		}
+
+		conn, err := s.dialNetwork(ctx, network, address, src)
+		if err == nil {
+			localName := s.LocalName
+			if src != nil && src.LocalName != "" {
+				localName = src.LocalName
+			}
+			return conn, localName, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, "", errors.Join(errs...)
+}
+
+// dialNetwork 使用 Dialer 连接 address，src 不为 nil 时绑定本地地址。
+// 只有直接连接（Dialer 为 nil 或 *net.Dialer）时才能绑定本地地址。
+func (s *SMTP) dialNetwork(ctx context.Context, network, address string, src *SourceAddr) (net.Conn, error) {
+	var d *net.Dialer
+	switch nd := s.Dialer.(type) {
+	case nil:
+		d = &net.Dialer{Timeout: s.dialTimeout()}
+	case *net.Dialer:
+		c := *nd
+		d = &c
+	}
+	if d == nil {
+		if src != nil {
+			utils.Logger.Debugln(utils.LogPrefix, "source address", src.IP, "is ignored by the custom Dialer")
+		}
+		ctx, cancel := context.WithTimeout(ctx, s.dialTimeout())
+		defer cancel()
+		return s.Dialer.DialContext(ctx, network, address)
+	}
+
+	if src != nil {
+		d.LocalAddr = &net.TCPAddr{IP: src.IP}
+	}
+	if d.Timeout <= 0 {
+		d.Timeout = s.dialTimeout()
+	}
+	return d.DialContext(ctx, network, address)
+}
+
+// LocalAddr 返回连接的本地地址，未连接时返回 nil。
+func (s *SMTPSender) LocalAddr() net.Addr {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr()
+}
@@ -0,0 +1,189 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottleMode 超过发送速率时的处理方式。
+type ThrottleMode int
+
+const (
+	// ThrottleBlock 等待直到可以发送，或 ctx 结束。默认值。
+	ThrottleBlock ThrottleMode = iota
+	// ThrottleFail 立即返回 ErrRateLimited。
+	ThrottleFail
+)
+
+// RateLimit 发送速率限制，0 表示不限制。
+type RateLimit struct {
+	// MessagesPerSecond 每秒最多发送的邮件数。
+	MessagesPerSecond float64
+	// RecipientsPerMinute 每分钟最多的收件人数。
+	RecipientsPerMinute float64
+}
+
+// Throttle 以令牌桶限制发送速率，可同时设置全局、每个中继与每个收件人域名的限制，
+// 每封邮件须同时满足所有限制。令牌桶的容量为一个周期的配额，即允许一秒（一分钟）内的突发。
+//
+// 将同一个 Throttle 设置到多个 SMTP 的 Throttle 字段，它们共享全局限制；
+// 连接池、FailoverSender、Balancer 中的连接同样受其约束。
+// 开始使用后不应再修改其中的限制。Throttle 可以在多个 goroutine 中同时使用。
+type Throttle struct {
+	// Mode 超过速率时等待还是返回错误，默认等待。
+	Mode ThrottleMode
+	// Global 所有邮件的总速率。
+	Global RateLimit
+	// Relay 每个中继服务器（host:port）的速率，Relays 中未设置的中继使用此限制。
+	Relay RateLimit
+	// Relays 指定中继服务器的速率，中继地址 host:port -> 限制。
+	Relays map[string]RateLimit
+	// Domain 每个收件人域名的速率，Domains 中未设置的域名使用此限制。
+	// 邮件数按包含该域名收件人的邮件计算，收件人数只计算该域名的收件人。
+	Domain RateLimit
+	// Domains 指定收件人域名的速率，域名（小写）-> 限制。
+	Domains map[string]RateLimit
+
+	mtx     sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewThrottle 创建一个只有全局限制的 Throttle。
+func NewThrottle(global RateLimit, mode ThrottleMode) *Throttle {
+	return &Throttle{Global: global, Mode: mode}
+}
+
+// bucket 令牌桶，tokens 可以为负数：表示已预支的令牌，之后的请求需等待更久。
+type bucket struct {
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 容量
+	tokens float64
+	last   time.Time
+}
+
+// advance 按经过的时间补充令牌。
+func (b *bucket) advance(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+	}
+	b.tokens = min(b.tokens, b.burst)
+	b.last = now
+}
+
+// delay 返回取得 n 个令牌需要等待的时间。n 超过容量时，等到令牌桶装满即可。
+func (b *bucket) delay(n float64) time.Duration {
+	n = min(n, b.burst)
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// request 一次发送需要从某个令牌桶取得的令牌。
+type request struct {
+	key   string
+	n     float64
+	rate  float64
+	burst float64
+}
+
+// Wait 等待直到可以向中继 relay（host:port）发送一封收件人为 to 的邮件，并扣除相应的配额。
+// Mode 为 ThrottleFail 时，超过速率立即返回 ErrRateLimited，不扣除配额。
+// ctx 结束时返回 ctx 的错误，并归还已扣除的配额。
+func (t *Throttle) Wait(ctx context.Context, relay string, to []string) error {
+	if t == nil {
+		return nil
+	}
+	reqs := t.requests(relay, to)
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	t.mtx.Lock()
+	if t.buckets == nil {
+		t.buckets = make(map[string]*bucket)
+	}
+	now := time.Now()
+	var wait time.Duration
+	var limited string
+	for _, r := range reqs {
+		b := t.buckets[r.key]
+		if b == nil {
+			b = &bucket{rate: r.rate, burst: r.burst, tokens: r.burst}
+			t.buckets[r.key] = b
+		}
+		b.advance(now)
+		if d := b.delay(r.n); d > wait {
+			wait, limited = d, r.key
+		}
+	}
+	if wait > 0 && t.Mode == ThrottleFail {
+		t.mtx.Unlock()
+		return fmt.Errorf("%w: %s", ErrRateLimited, limited)
+	}
+	for _, r := range reqs {
+		t.buckets[r.key].tokens -= r.n
+	}
+	t.mtx.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		t.mtx.Lock()
+		for _, r := range reqs {
+			t.buckets[r.key].tokens += r.n
+		}
+		t.mtx.Unlock()
+		return ctx.Err()
+	}
+}
+
+// requests 返回一封邮件需要取得的令牌。
+func (t *Throttle) requests(relay string, to []string) []request {
+	var reqs []request
+	add := func(key string, limit RateLimit, rcpts int) {
+		if limit.MessagesPerSecond > 0 {
+			reqs = append(reqs, request{key + "/msg", 1, limit.MessagesPerSecond, max(limit.MessagesPerSecond, 1)})
+		}
+		if limit.RecipientsPerMinute > 0 {
+			reqs = append(reqs, request{key + "/rcpt", float64(rcpts), limit.RecipientsPerMinute / 60, max(limit.RecipientsPerMinute, 1)})
+		}
+	}
+
+	add("global", t.Global, len(to))
+	if limit, ok := t.Relays[relay]; ok {
+		add("relay/"+relay, limit, len(to))
+	} else {
+		add("relay/"+relay, t.Relay, len(to))
+	}
+
+	if t.Domain == (RateLimit{}) && len(t.Domains) == 0 {
+		return reqs
+	}
+	var domains []string
+	count := make(map[string]int)
+	for _, addr := range to {
+		domain := strings.ToLower(addr[strings.LastIndexByte(addr, '@')+1:])
+		if count[domain] == 0 {
+			domains = append(domains, domain)
+		}
+		count[domain]++
+	}
+	for _, domain := range domains {
+		limit, ok := t.Domains[domain]
+		if !ok {
+			limit = t.Domain
+		}
+		add("domain/"+domain, limit, count[domain])
+	}
+	return reqs
+}
@@ -0,0 +1,94 @@
+package smtp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TLSPolicy 非隐式 TLS 连接（SSL 为 false）是否使用 STARTTLS 扩展升级为 TLS 连接。
+type TLSPolicy int
+
+const (
+	// TLSOpportunistic 服务器支持 STARTTLS 时升级为 TLS 连接，否则使用明文连接。默认值。
+	TLSOpportunistic TLSPolicy = iota
+	// TLSMandatory 必须升级为 TLS 连接，服务器不支持 STARTTLS 时返回 ErrTLSRequired。
+	TLSMandatory
+	// NoTLS 不使用 STARTTLS，始终使用明文连接。
+	NoTLS
+)
+
+func (p TLSPolicy) String() string {
+	switch p {
+	case TLSOpportunistic:
+		return "Opportunistic"
+	case TLSMandatory:
+		return "Mandatory"
+	case NoTLS:
+		return "NoTLS"
+	}
+	return fmt.Sprintf("TLSPolicy(%d)", int(p))
+}
+
+// tlsCfg 返回 TLS 连接使用的配置：在 TLSConfig 的基础上应用 MinTLSVersion 与 TLSFingerprints。
+func (s *SMTP) tlsCfg() *tls.Config {
+	var cfg *tls.Config
+	if s.TLSConfig == nil {
+		cfg = &tls.Config{ServerName: s.host} // default
+	} else {
+		cfg = s.TLSConfig.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName = s.host
+		}
+	}
+
+	if s.MinTLSVersion > cfg.MinVersion {
+		cfg.MinVersion = s.MinTLSVersion
+	}
+	if len(s.TLSFingerprints) > 0 {
+		pins := make(map[string]bool, len(s.TLSFingerprints))
+		for _, fp := range s.TLSFingerprints {
+			pins[normalizeFingerprint(fp)] = true
+		}
+		verify := cfg.VerifyConnection
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(cs); err != nil {
+					return err
+				}
+			}
+			return verifyFingerprint(cs, pins)
+		}
+	}
+	return cfg
+}
+
+// verifyFingerprint 检查服务器证书的 SHA-256 指纹是否在 pins 中。
+func verifyFingerprint(cs tls.ConnectionState, pins map[string]bool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("%w: no certificate", ErrFingerprintMismatch)
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+	fp := hex.EncodeToString(sum[:])
+	if !pins[fp] {
+		return fmt.Errorf("%w: got %s", ErrFingerprintMismatch, fp)
+	}
+	return nil
+}
+
+// normalizeFingerprint 将指纹转换为不含分隔符的小写十六进制形式，
+// 如 "AB:CD:..." -> "abcd..."。
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(fp))
+}
+
+// TLSConnectionState 返回连接的 TLS 状态（协议版本、密码套件、服务器证书等），
+// 未连接或未使用 TLS 时 ok 为 false。
+func (s *SMTPSender) TLSConnectionState() (state tls.ConnectionState, ok bool) {
+	if s.client == nil {
+		return tls.ConnectionState{}, false
+	}
+	return s.client.TLSConnectionState()
+}
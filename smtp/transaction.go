@@ -0,0 +1,472 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/JiuYu77/go-email/utils"
+)
+
+// extension 报告服务器是否支持扩展 ext，以及该扩展的参数。
+// LMTP 模式下使用 LHLO 响应中的扩展。
+func (s *SMTPSender) extension(ext Extension) (bool, string) {
+	if s.smtp.LMTP {
+		v, ok := s.lmtpExt[strings.ToUpper(ext)]
+		return ok, v
+	}
+	return s.client.Extension(ext)
+}
+
+// cmd 发送一条命令并读取响应，与 net/smtp 中 Client 的同名方法相同；
+// 服务器的错误响应转换为 *SMTPError。
+func (s *SMTPSender) cmd(expectCode int, format string, args ...any) (int, string, error) {
+	text := s.client.Text
+	line := fmt.Sprintf(format, args...)
+	id, err := text.Cmd("%s", line)
+	if err != nil {
+		return 0, "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	code, msg, err := text.ReadResponse(expectCode)
+	return code, msg, newSMTPError(commandOf(line), err)
+}
+
+// reset 发送 RSET 命令，放弃当前事务，使连接可以继续发送下一封邮件。
+func (s *SMTPSender) reset() error {
+	_, _, err := s.cmd(250, "RSET")
+	return err
+}
+
+// mailCmd 构造 MAIL 命令，params 为附加在地址之后的参数，如 BODY=8BITMIME、SMTPUTF8。
+func mailCmd(from string, params []string) string {
+	cmd := "MAIL FROM:<" + from + ">"
+	for _, p := range params {
+		cmd += " " + p
+	}
+	return cmd
+}
+
+// validateLine 检查地址中是否含有换行符，防止命令注入。
+func validateLine(line string) error {
+	if strings.ContainsAny(line, "\n\r") {
+		return errors.New("smtp: A line must not contain CR or LF")
+	}
+	return nil
+}
+
+// sendMail 完成一次邮件事务：NOOP 检查、MAIL、RCPT、DATA。
+//
+// 服务器支持 PIPELINING 扩展时，MAIL、全部 RCPT 与 DATA 合并为一次写入，
+// 否则逐条发送命令并等待响应。
+//
+// 服务器支持 CHUNKING 扩展时，以 BDAT 命令分块发送邮件内容，不再使用 DATA；
+// 若同时支持 BINARYMIME，*Message 的附件不再编码，原样发送。
+//
+// partial 为 false 时，任一收件人被拒绝即放弃本次事务；
+// 为 true 时，只要有一个收件人被接收就继续发送邮件内容。
+func (s *SMTPSender) sendMail(ctx context.Context, env *Envelope, msg io.WriterTo, partial bool) (*SendResult, error) {
+	from, to := env.From, env.To
+	if len(to) == 0 {
+		return nil, ErrNoRecipients
+	}
+	if err := validateLine(from); err != nil {
+		return nil, err
+	}
+	for _, addr := range to {
+		if err := validateLine(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.smtp.Throttle.Wait(ctx, s.smtp.relay(), to); err != nil {
+		return nil, err
+	}
+
+	if err := s.setDeadline(ctx, s.smtp.CommandTimeout); err != nil {
+		return nil, err
+	}
+	if err := s.Noop(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConnectionLost, err)
+	}
+	s.messages++
+
+	dsn := env.DSN
+	if dsn == nil {
+		dsn = s.smtp.DSN
+	}
+	if dsn != nil {
+		if err := dsn.validate(); err != nil {
+			return nil, err
+		}
+		if ok, _ := s.extension(SMTPExtDSN); !ok {
+			utils.Logger.Debugln(utils.LogPrefix, "DSN is not supported by the server, ignore DSN parameters")
+			dsn = nil
+		}
+	}
+
+	// 国际化邮箱地址：服务器支持 SMTPUTF8 时原样发送，否则将域名转换为 punycode
+	utf8 := needsSMTPUTF8(from, to, msg)
+	asciiDomain := false
+	if ok, _ := s.extension(SMTPExtSMTPUTF8); utf8 && !ok {
+		var err error
+		if from, to, err = asciiEnvelope(from, to); err != nil {
+			return nil, err
+		}
+		utf8, asciiDomain = false, true
+	}
+
+	chunking, _ := s.extension(SMTPExtChunking)
+	binary := false
+	if _, ok := msg.(*Message); ok && chunking {
+		binary, _ = s.extension(SMTPExtBinaryMIME)
+	}
+
+	var params []string
+	switch ok, _ := s.extension(SMTPExt8BitMIME); {
+	case binary:
+		params = append(params, "BODY=BINARYMIME")
+	case ok && (utf8 || has8bit(msg)):
+		params = append(params, "BODY=8BITMIME")
+	}
+	if utf8 {
+		params = append(params, "SMTPUTF8")
+	}
+	if dsn != nil {
+		params = append(params, dsn.mailParams()...)
+	}
+	mail := mailCmd(from, params)
+
+	rcpts := make([]string, len(to))
+	for i, addr := range to {
+		rcpts[i] = "RCPT TO:<" + addr + ">"
+		if dsn != nil {
+			// ORCPT 使用转换为 punycode 之前的原始地址
+			for _, p := range dsn.rcptParams(env.To[i]) {
+				rcpts[i] += " " + p
+			}
+		}
+	}
+
+	if m, ok := msg.(*Message); ok {
+		msg = writerToFunc(func(w io.Writer) (int64, error) {
+			return m.writeTo(&MessageWriter{w: w, binary: binary, utf8: utf8, asciiDomain: asciiDomain})
+		})
+	}
+
+	var (
+		replies []reply
+		err     error
+	)
+	if ok, _ := s.extension(SMTPExtPipelining); ok {
+		replies, err = s.envelopePipelined(ctx, mail, rcpts, !chunking, partial)
+	} else {
+		replies, err = s.envelopeLockstep(ctx, mail, rcpts, !chunking, partial)
+	}
+	var result *SendResult
+	if replies != nil {
+		result = newSendResult(env.To, replies)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	var final []reply
+	if chunking {
+		final, err = s.bdat(ctx, msg, binary, accepted(replies))
+	} else {
+		final, err = s.data(ctx, msg, accepted(replies))
+	}
+	if s.smtp.LMTP && final != nil {
+		// LMTP：每个收件人有各自的投递结果
+		replies = lmtpReplies(replies, final)
+		result = newSendResult(env.To, replies)
+		if err != nil && accepted(replies) > 0 {
+			if partial {
+				err = nil
+			} else {
+				err = &PartialDeliveryError{Result: result, Err: err}
+			}
+		}
+		return result, err
+	}
+	if err != nil {
+		result.fail(err)
+	}
+	return result, err
+}
+
+// has8bit 报告邮件内容是否含有 8bit 数据，无法判断时视为含有。
+func has8bit(msg io.WriterTo) bool {
+	switch m := msg.(type) {
+	case *Message:
+		return m.has8bitParts()
+	case rawMessage:
+		return !isASCII(string(m))
+	}
+	return true
+}
+
+// asciiEnvelope 将信封中国际化地址的域名转换为 punycode。
+func asciiEnvelope(from string, to []string) (string, []string, error) {
+	from, err := asciiAddress(from)
+	if err != nil {
+		return "", nil, err
+	}
+	list := make([]string, len(to))
+	for i, addr := range to {
+		if list[i], err = asciiAddress(addr); err != nil {
+			return "", nil, err
+		}
+	}
+	return from, list, nil
+}
+
+// rawMessage 是 []byte 形式的完整邮件内容。
+type rawMessage []byte
+
+// RawMessage 将 []byte 形式的完整邮件包装为 io.WriterTo，用于 SendEnvelope、SendPartial 等。
+// 与 bytes.Reader 不同，发送前可以检查邮件内容，只在需要时声明 BODY=8BITMIME 与 SMTPUTF8。
+func RawMessage(msg []byte) io.WriterTo {
+	return rawMessage(msg)
+}
+
+func (m rawMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(m)
+	return int64(n), err
+}
+
+// header 返回邮件的头信息部分。
+func (m rawMessage) header() string {
+	if i := bytes.Index(m, []byte("\r\n\r\n")); i >= 0 {
+		return string(m[:i])
+	}
+	if i := bytes.Index(m, []byte("\n\n")); i >= 0 {
+		return string(m[:i])
+	}
+	return string(m)
+}
+
+// writerToFunc 将函数适配为 io.WriterTo。
+type writerToFunc func(io.Writer) (int64, error)
+
+func (f writerToFunc) WriteTo(w io.Writer) (int64, error) {
+	return f(w)
+}
+
+// envelopeLockstep 逐条发送 MAIL、RCPT、DATA 命令，每条命令都等待服务器响应。
+// rcpts 为完整的 RCPT 命令；withData 为 false 时不发送 DATA 命令（使用 BDAT 发送邮件内容）。
+//
+// 返回每条 RCPT 命令的响应；partial 为 false 时，第一个被拒绝的收件人即结束事务，此时不返回响应。
+func (s *SMTPSender) envelopeLockstep(ctx context.Context, mail string, rcpts []string, withData, partial bool) ([]reply, error) {
+	if _, _, err := s.cmd(250, "%s", mail); err != nil {
+		s.reset()
+		return nil, err
+	}
+
+	replies := make([]reply, len(rcpts))
+	var rcptErr error
+	for i, rcpt := range rcpts {
+		if err := s.setDeadline(ctx, s.smtp.CommandTimeout); err != nil {
+			return nil, err
+		}
+		code, msg, err := s.cmd(25, "%s", rcpt)
+		replies[i] = reply{code: code, msg: msg, err: err}
+		if err != nil && (!partial || code == 0) { // code 为 0 表示连接错误
+			s.reset()
+			return nil, err
+		}
+		if rcptErr == nil {
+			rcptErr = err
+		}
+	}
+	if accepted(replies) == 0 {
+		s.reset()
+		return replies, rcptErr
+	}
+	if !withData {
+		return replies, nil
+	}
+
+	if err := s.setDeadline(ctx, s.smtp.DataTimeout); err != nil {
+		return nil, err
+	}
+	_, _, err := s.cmd(354, "DATA")
+	return replies, err
+}
+
+// envelopePipelined 将 MAIL、全部 RCPT 与 DATA 命令一次写出（RFC 2920），
+// 再按发送顺序逐一读取响应，并将错误归属到对应的命令。
+// rcpts 为完整的 RCPT 命令；withData 为 false 时不发送 DATA 命令（使用 BDAT 发送邮件内容）。
+//
+// 返回每条 RCPT 命令的响应；partial 为 false 时，有收件人被拒绝即放弃事务，此时不返回响应。
+func (s *SMTPSender) envelopePipelined(ctx context.Context, mail string, rcpts []string, withData, partial bool) ([]reply, error) {
+	text := s.client.Text
+	w := text.W
+	w.WriteString(mail + "\r\n")
+	for _, rcpt := range rcpts {
+		w.WriteString(rcpt + "\r\n")
+	}
+	if withData {
+		w.WriteString("DATA\r\n")
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	_, _, mailErr := text.ReadResponse(250)
+	mailErr = newSMTPError("MAIL", mailErr)
+	replies := make([]reply, len(rcpts))
+	var rcptErr error
+	for i := range rcpts {
+		rp, err := readReply(text, "RCPT", 25)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = rp
+		if rcptErr == nil {
+			rcptErr = rp.err
+		}
+	}
+	var dataErr error
+	if withData {
+		if err := s.setDeadline(ctx, s.smtp.DataTimeout); err != nil {
+			return nil, err
+		}
+		_, _, dataErr = text.ReadResponse(354)
+		dataErr = newSMTPError("DATA", dataErr)
+	}
+
+	if mailErr != nil || accepted(replies) == 0 || (rcptErr != nil && !partial) {
+		if withData && dataErr == nil {
+			// 服务器已接受 DATA，此时发送结束符会把邮件投递给已接受的收件人，
+			// 只能断开连接来放弃本次事务。
+			s.abort()
+		} else {
+			s.reset()
+		}
+		if mailErr != nil {
+			return nil, mailErr
+		}
+		if !partial {
+			return nil, rcptErr
+		}
+		return replies, rcptErr
+	}
+	return replies, dataErr
+}
+
+// accepted 返回被服务器接收的收件人数量。
+func accepted(replies []reply) int {
+	n := 0
+	for _, rp := range replies {
+		if rp.err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// abort 断开连接，用于无法通过 RSET 放弃的事务（如写入邮件内容时出错）。
+func (s *SMTPSender) abort() {
+	s.client.Close()
+	s.client = nil
+	s.conn = nil
+}
+
+// data 在 DATA 命令被接受后写入邮件内容，并等待服务器确认接收。
+// n 为已接收的收件人数量，LMTP 模式下服务器为每个收件人各返回一条响应，见 dataReplies。
+func (s *SMTPSender) data(ctx context.Context, msg io.WriterTo, n int) ([]reply, error) {
+	w := s.client.Text.DotWriter()
+	if _, err := msg.WriteTo(&ctxWriter{ctx: ctx, w: w}); err != nil {
+		// 不能写出结束符，否则不完整的邮件会被投递
+		s.abort()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return s.dataReplies("DATA", n)
+}
+
+// bdat 以 BDAT 命令分块发送邮件内容（RFC 3030），每块大小为 SMTP.ChunkSize。
+// binary 为 false 时，与 DATA 命令一样将单独的 LF 转换为 CRLF；n 与 data 相同。
+func (s *SMTPSender) bdat(ctx context.Context, msg io.WriterTo, binary bool, n int) ([]reply, error) {
+	if err := s.setDeadline(ctx, s.smtp.DataTimeout); err != nil {
+		return nil, err
+	}
+
+	cw := &chunkWriter{s: s, buf: make([]byte, 0, s.smtp.chunkSize())}
+	var w io.Writer = cw
+	if !binary {
+		w = &crlfWriter{w: cw}
+	}
+	if _, err := msg.WriteTo(&ctxWriter{ctx: ctx, w: w}); err != nil {
+		if cw.err == nil {
+			// 未发送 LAST 块，服务器不会投递邮件；断开连接以放弃本次事务
+			s.abort()
+		}
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return s.dataReplies("BDAT", n)
+}
+
+// chunkWriter 缓存写入的数据，每满一块就以一条 BDAT 命令发送，Close 时发送最后一块。
+type chunkWriter struct {
+	s   *SMTPSender
+	buf []byte
+	err error // 服务器对 BDAT 命令的错误响应
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		k := min(cap(w.buf)-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:k]...)
+		p = p[k:]
+		n += k
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close 发送最后一块（BDAT n LAST），服务器的最终响应由 dataReplies 读取。
+func (w *chunkWriter) Close() error {
+	return w.flush(true)
+}
+
+func (w *chunkWriter) flush(last bool) error {
+	text := w.s.client.Text
+	cmd := fmt.Sprintf("BDAT %d", len(w.buf))
+	if last {
+		cmd += " LAST"
+	}
+	text.W.WriteString(cmd + "\r\n")
+	text.W.Write(w.buf)
+	if err := text.W.Flush(); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	if last {
+		return nil
+	}
+
+	if _, _, err := text.ReadResponse(250); err != nil {
+		err = newSMTPError("BDAT", err)
+		w.err = err
+		w.s.reset()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package smtp
+
+import (
+	"context"
+)
+
+// Transport 发送邮件的传输方式，由 SMTPSender、SMTPSenderPool、SMTP、FailoverSender、
+// Balancer、DirectSender 实现；依赖 Transport 而不是具体类型的代码可以替换传输方式，
+// 或在测试中使用替身。
+type Transport interface {
+	// SendMessage 发送邮件 m，发件人取自 From，收件人取自 To、Cc、Bcc。
+	SendMessage(ctx context.Context, m *Message) error
+	// SendRaw 按信封 env 发送原始邮件内容 msg（RFC 5322 格式）。
+	// env.From 为空时使用传输方式配置的发件人。
+	SendRaw(ctx context.Context, env *Envelope, msg []byte) error
+}
+
+var (
+	_ Transport = (*SMTPSender)(nil)
+	_ Transport = (*SMTPSenderPool)(nil)
+	_ Transport = (*SMTP)(nil)
+	_ Transport = (*FailoverSender)(nil)
+	_ Transport = (*Balancer)(nil)
+	_ Transport = (*DirectSender)(nil)
+)
+
+// SendMessage 实现 Transport，连接须已建立。
+func (s *SMTPSender) SendMessage(ctx context.Context, m *Message) error {
+	return s.send(ctx, false, m)
+}
+
+// SendRaw 实现 Transport，连接须已建立；env.From 为空时使用 SMTP 的发件人。
+func (s *SMTPSender) SendRaw(ctx context.Context, env *Envelope, msg []byte) error {
+	if env.From == "" {
+		e := *env
+		e.From = s.smtp.from
+		env = &e
+	}
+	return s.SendEnvelope(ctx, env, rawMessage(msg))
+}
+
+// SendMessage 实现 Transport：从连接池取出连接发送邮件，发送后放回。
+func (p *SMTPSenderPool) SendMessage(ctx context.Context, m *Message) error {
+	return p.with(ctx, func(sender *SMTPSender) error {
+		return sender.SendMessage(ctx, m)
+	})
+}
+
+// SendRaw 实现 Transport：从连接池取出连接发送邮件，发送后放回。
+func (p *SMTPSenderPool) SendRaw(ctx context.Context, env *Envelope, msg []byte) error {
+	return p.with(ctx, func(sender *SMTPSender) error {
+		return sender.SendRaw(ctx, env, msg)
+	})
+}
+
+func (p *SMTPSenderPool) with(ctx context.Context, fn func(sender *SMTPSender) error) error {
+	sender, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Put(sender)
+	return fn(sender)
+}
+
+// SendMessage 实现 Transport：每次发送建立新的连接，按 RetryPolicy 重试，同 DialAndSend。
+func (s *SMTP) SendMessage(ctx context.Context, m *Message) error {
+	return s.dialAndSend(ctx, 1, func(sender *SMTPSender, _ int) error {
+		return sender.SendMessage(ctx, m)
+	})
+}
+
+// SendRaw 实现 Transport：每次发送建立新的连接，按 RetryPolicy 重试，同 DialAndSend2。
+func (s *SMTP) SendRaw(ctx context.Context, env *Envelope, msg []byte) error {
+	return s.dialAndSend(ctx, 1, func(sender *SMTPSender, _ int) error {
+		return sender.SendRaw(ctx, env, msg)
+	})
+}
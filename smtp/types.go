@@ -37,6 +37,9 @@ const (
 	// Unencoded can be used to avoid encoding the body of an email. The headers
 	// will still be encoded using quoted-printable encoding.
 	Unencoded Encoding = "8bit"
+	// Binary 不编码、不限制行长的原始内容，仅在服务器支持 BINARYMIME 扩展（RFC 3030）时，
+	// 用于以 BDAT 命令发送的附件。
+	Binary Encoding = "binary"
 )
 
 type mimeEncoder = mime.WordEncoder
@@ -166,7 +169,9 @@ func (w *base64LineWriter) Write(p []byte) (int, error) {
 
 // ConnectionMonitor 用于监控 *smtp.Client 连接状态，保持连接活跃.
 type ConnectionMonitor struct {
-	Sender       *SMTPSender
+	Sender *SMTPSender
+	// Retry 连接断开后重新连接的重试策略，nil 时最多尝试 5 次，等待时间从 1 秒开始翻倍，最长 30 秒。
+	Retry        *RetryPolicy
 	isMonitoring bool
 	mtx          sync.Mutex
 }
@@ -178,8 +183,16 @@ func (cm *ConnectionMonitor) IsMonitoring() bool {
 	return cm.isMonitoring
 }
 
+func (cm *ConnectionMonitor) retry() *RetryPolicy {
+	if cm.Retry != nil {
+		return cm.Retry
+	}
+	return &RetryPolicy{MaxAttempts: 5, MaxBackoff: 30 * time.Second, Jitter: 0.5}
+}
+
 // 定期检查连接状态、重连.
 // 可用于长时间处理邮件时保持连接.
+// 重连按 Retry 的策略退避重试，用尽后停止监控；ctx 结束时立即停止。
 func (cm *ConnectionMonitor) MonitorConnection(ctx context.Context, d time.Duration) {
 	cm.mtx.Lock()
 	defer cm.mtx.Unlock()
@@ -188,44 +201,38 @@ func (cm *ConnectionMonitor) MonitorConnection(ctx context.Context, d time.Durat
 		log.Println("MonitorConnection is already running")
 		return
 	}
+	cm.isMonitoring = true
 
 	go func() {
-		cm.isMonitoring = true
+		defer func() {
+			cm.mtx.Lock()
+			cm.isMonitoring = false
+			cm.mtx.Unlock()
+		}()
+
 		ticker := time.NewTicker(d)
 		defer ticker.Stop()
 
-		retryCount := 0
-		const maxRetries int = 5
-
 		for {
 			select {
 			case <-ctx.Done():
-				cm.isMonitoring = false
 				return
 			case <-ticker.C:
-				if err := cm.Sender.Noop(); err != nil { // 触发重连逻辑
-					log.Printf("SMTP connection check failed: %v", err)
-
-					// 指数退避重试
-					backoff := min(time.Duration(retryCount)*time.Second, 30*time.Second)
-					time.Sleep(backoff)
-
-					// 尝试重连
-					if err := cm.Sender.Dial(); err != nil {
-						log.Printf("SMTP reconnection failed: %v", err)
-						retryCount++
-						if retryCount >= maxRetries {
-							log.Println("Max reconnection retries exceeded, stopping monitor")
-							cm.isMonitoring = false
-							return
-						}
-					} else {
-						log.Println("SMTP reconnection successful")
-						retryCount = 0
-					}
-				} else {
-					retryCount = 0
+				err := cm.Sender.Noop()
+				if err == nil {
+					continue
+				}
+				log.Printf("SMTP connection check failed: %v", err)
+
+				// 尝试重连，指数退避重试
+				err = cm.retry().Do(ctx, func(ctx context.Context) error {
+					return cm.Sender.DialContext(ctx)
+				})
+				if err != nil {
+					log.Printf("SMTP reconnection failed, stopping monitor: %v", err)
+					return
 				}
+				log.Println("SMTP reconnection successful")
 			}
 		}
 	}()
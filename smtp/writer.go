@@ -1,9 +1,11 @@
 package smtp
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"io"
+	"maps"
 	"mime"
 	"mime/multipart"
 	"path/filepath"
@@ -19,8 +21,18 @@ type MessageWriter struct {
 	partWriter io.Writer
 	depth      uint8
 	err        error
+
+	// binary 为 true 时，附件以 binary 编码原样写入（BINARYMIME，RFC 3030）。
+	binary bool
+	// utf8 为 true 时，头信息中的 RFC 2047 编码字解码为 UTF-8 原样写入（SMTPUTF8，RFC 6532）。
+	utf8 bool
+	// asciiDomain 为 true 时，地址头信息中的国际化域名转换为 IDNA（punycode）形式，
+	// 用于服务器不支持 SMTPUTF8 的情况。
+	asciiDomain bool
 }
 
+var headerDecoder = new(mime.WordDecoder)
+
 // createPart 创建 multipart.Writer 部分
 func (w *MessageWriter) createPart(h Header) {
 	w.partWriter, w.err = w.writers[w.depth-1].CreatePart(h)
@@ -61,10 +73,14 @@ func (w *MessageWriter) Write(p []byte) (n int, err error) {
 	return n, w.err
 }
 
-// writeString 写入字符串到 io.Writer
+// writeString 写入字符串到 io.Writer。出错后不再写入，错误保存在 w.err 中。
 func (w *MessageWriter) writeString(s string) {
-	n, _ := io.WriteString(w.w, s)
+	if w.err != nil {
+		return
+	}
+	n, err := io.WriteString(w.w, s)
 	w.n += int64(n)
+	w.err = err
 }
 
 func (w *MessageWriter) writeLine(s string, charsLeft int) string {
@@ -147,7 +163,7 @@ func (w *MessageWriter) writeHeaders(h Header) {
 	if w.depth == 0 {
 		for k, v := range h {
 			if k != "Bcc" {
-				w.writeHeader(k, v...)
+				w.writeHeader(k, w.headerValues(k, v)...)
 			}
 		}
 	} else {
@@ -155,7 +171,34 @@ func (w *MessageWriter) writeHeaders(h Header) {
 	}
 }
 
+// headerValues 按 utf8、asciiDomain 设置转换邮件头的值，不修改原值。
+func (w *MessageWriter) headerValues(key string, values []string) []string {
+	if !w.utf8 && !(w.asciiDomain && addressHeaders[key]) {
+		return values
+	}
+
+	out := make([]string, len(values))
+	for i, v := range values {
+		switch {
+		case w.asciiDomain && addressHeaders[key]:
+			v = asciiAddressList(v)
+		case addressHeaders[key]:
+			v = utf8AddressList(v)
+		default:
+			if dec, err := headerDecoder.DecodeHeader(v); err == nil {
+				v = dec
+			}
+		}
+		out[i] = v
+	}
+	return out
+}
+
 func (w *MessageWriter) writeBody(f func(io.Writer) error, enc Encoding) {
+	if w.err != nil {
+		return
+	}
+
 	var subWriter io.Writer
 	if w.depth == 0 {
 		w.writeString("\r\n")
@@ -169,7 +212,7 @@ func (w *MessageWriter) writeBody(f func(io.Writer) error, enc Encoding) {
 		wc := base64.NewEncoder(base64.StdEncoding, newBase64LineWriter(subWriter))
 		w.err = f(wc)
 		wc.Close()
-	case Unencoded:
+	case Unencoded, Binary:
 		w.err = f(subWriter)
 	default:
 		wc := newQPWriter(subWriter)
@@ -188,35 +231,41 @@ func (w *MessageWriter) writePart(p *part, charset string) {
 
 func (w *MessageWriter) addFiles(files []*file, isAttachment bool) {
 	for _, f := range files {
-		if _, ok := f.Header["Content-Type"]; !ok {
+		// 在副本上补全头信息，同一封邮件可能以不同方式（DATA、BDAT）多次发送
+		h := maps.Clone(f.Header)
+		if _, ok := h["Content-Type"]; !ok {
 			mediaType := mime.TypeByExtension(filepath.Ext(f.Name))
 			if mediaType == "" {
 				mediaType = "application/octet-stream"
 			}
-			f.setHeader("Content-Type", mediaType+`; name="`+f.Name+`"`)
+			h["Content-Type"] = []string{mediaType + `; name="` + f.Name + `"`}
 		}
 
-		if _, ok := f.Header["Content-Transfer-Encoding"]; !ok {
-			f.setHeader("Content-Transfer-Encoding", string(Base64))
+		enc := Base64
+		if _, ok := h["Content-Transfer-Encoding"]; !ok {
+			if w.binary {
+				enc = Binary
+			}
+			h["Content-Transfer-Encoding"] = []string{string(enc)}
 		}
 
-		if _, ok := f.Header["Content-Disposition"]; !ok {
+		if _, ok := h["Content-Disposition"]; !ok {
 			var disp string
 			if isAttachment {
 				disp = "attachment"
 			} else {
 				disp = "inline"
 			}
-			f.setHeader("Content-Disposition", disp+`; filename="`+f.Name+`"`)
+			h["Content-Disposition"] = []string{disp + `; filename="` + f.Name + `"`}
 		}
 
 		if !isAttachment {
-			if _, ok := f.Header["Content-ID"]; !ok {
-				f.setHeader("Content-ID", "<"+f.Name+">")
+			if _, ok := h["Content-ID"]; !ok {
+				h["Content-ID"] = []string{"<" + f.Name + ">"}
 			}
 		}
-		w.writeHeaders(f.Header)
-		w.writeBody(f.CopyFunc, Base64)
+		w.writeHeaders(h)
+		w.writeBody(f.CopyFunc, enc)
 	}
 }
 
@@ -261,3 +310,50 @@ func (w *MessageWriter) writeMessage(m *Message) {
 		w.closeMultipart()
 	}
 }
+
+// ctxWriter 在每次写入前检查 ctx，ctx 结束后拒绝继续写入，
+// 使 MessageWriter、附件的 Copier 等尽早停止生成邮件内容。
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w *ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// crlfWriter 将单独的 LF 转换为 CRLF。
+// DATA 命令的 DotWriter 会做同样的转换，BDAT 命令则需要自行处理。
+type crlfWriter struct {
+	w    io.Writer
+	prev byte
+}
+
+func (w *crlfWriter) Write(p []byte) (int, error) {
+	start := 0
+	for i, b := range p {
+		if b != '\n' {
+			continue
+		}
+		if (i > 0 && p[i-1] == '\r') || (i == 0 && w.prev == '\r') {
+			continue
+		}
+		if _, err := w.w.Write(p[start:i]); err != nil {
+			return start, err
+		}
+		if _, err := io.WriteString(w.w, "\r\n"); err != nil {
+			return i, err
+		}
+		start = i + 1
+	}
+	if _, err := w.w.Write(p[start:]); err != nil {
+		return start, err
+	}
+	if len(p) > 0 {
+		w.prev = p[len(p)-1]
+	}
+	return len(p), nil
+}
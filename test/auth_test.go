@@ -0,0 +1,88 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+// authCommand 返回服务器收到的 AUTH 命令中的认证机制。
+func authCommand(server *fakeServer) string {
+	for _, cmd := range server.Commands() {
+		if mech, ok := strings.CutPrefix(cmd, "AUTH "); ok {
+			mech, _, _ = strings.Cut(mech, " ")
+			return mech
+		}
+	}
+	return ""
+}
+
+func TestScramSHA256(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"AUTH PLAIN CRAM-MD5 SCRAM-SHA-256"}
+	server.Users = map[string]string{"user": "secret"}
+	server.Start()
+
+	// 默认优先使用 SCRAM
+	s := goemail.NewSMTP(server.Host(), server.Port(), "user", "secret", "from@example.com")
+	s.AllowInsecureAuth = true
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	sender.Quit()
+	if mech := authCommand(server); mech != goemail.SMTPAuthScramSHA256 {
+		t.Errorf("auth mechanism = %q, want SCRAM-SHA-256", mech)
+	}
+
+	s = goemail.NewSMTP(server.Host(), server.Port(), "user", "wrong", "from@example.com")
+	s.AllowInsecureAuth = true
+	if _, err := s.Dial(); !goemail.IsAuthFailure(err) {
+		t.Errorf("dial with a wrong password: got %v, want an auth failure", err)
+	}
+}
+
+func TestAuthMechanisms(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"AUTH PLAIN SCRAM-SHA-256"}
+	server.Users = map[string]string{"user": "secret"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "user", "secret", "from@example.com")
+	s.AllowInsecureAuth = true
+	s.AuthMechanisms = []goemail.SMTPAuthType{goemail.SMTPAuthCramMD5, goemail.SMTPAuthPlain}
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	sender.Quit()
+	if mech := authCommand(server); mech != goemail.SMTPAuthPlain {
+		t.Errorf("auth mechanism = %q, want PLAIN", mech)
+	}
+
+	s.AuthMechanisms = []goemail.SMTPAuthType{goemail.SMTPAuthCramMD5}
+	if _, err := s.Dial(); !errors.Is(err, goemail.ErrNoAuthMechanism) {
+		t.Errorf("dial with an unsupported mechanism: got %v, want ErrNoAuthMechanism", err)
+	}
+}
+
+func TestSetAuth(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"AUTH PLAIN SCRAM-SHA-256"}
+	server.Users = map[string]string{"other": "secret"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "user", "wrong", "from@example.com")
+	s.AllowInsecureAuth = true
+	s.SetAuth(goemail.ScramSHA256Auth("other", "secret"))
+	// 同一个 smtp.Auth 用于多个连接
+	for range 2 {
+		sender, err := s.Dial()
+		if err != nil {
+			t.Fatal("dial:", err)
+		}
+		sender.Quit()
+	}
+}
@@ -0,0 +1,184 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestBalancerQuota(t *testing.T) {
+	a := newFakeServer(t).Start()
+	b := newFakeServer(t).Start()
+	state := filepath.Join(t.TempDir(), "quota.json")
+
+	accounts := func() []*goemail.Account {
+		return []*goemail.Account{
+			{Name: "a", SMTP: goemail.NewSMTP(a.Host(), a.Port(), "", "", "a@example.com"), DailyQuota: 2},
+			{Name: "b", SMTP: goemail.NewSMTP(b.Host(), b.Port(), "", "", "b@example.com"), HourlyQuota: 1},
+		}
+	}
+	balancer, err := goemail.NewBalancer(state, accounts()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &goemail.Envelope{To: []string{"to@example.com"}}
+	var used []string
+	for range 3 {
+		name, err := balancer.SendEnvelope(context.Background(), env, strings.NewReader("hello\r\n"))
+		if err != nil {
+			t.Fatal("SendEnvelope:", err)
+		}
+		used = append(used, name)
+	}
+	if got := strings.Join(used, ","); got != "a,b,a" {
+		t.Errorf("accounts used = %s", got)
+	}
+	if mails := b.Mails(); len(mails) != 1 || mails[0].From != "b@example.com" {
+		t.Errorf("account b sent %+v", mails)
+	}
+	if _, err := balancer.SendEnvelope(context.Background(), env, strings.NewReader("hello\r\n")); !errors.Is(err, goemail.ErrQuotaExhausted) {
+		t.Errorf("err = %v, want ErrQuotaExhausted", err)
+	}
+
+	// 重启后继续使用保存的计数
+	balancer, err = goemail.NewBalancer(state, accounts()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := balancer.Usage()
+	if usage[0].DailySent != 2 || usage[1].HourlySent != 1 {
+		t.Errorf("usage after restart = %+v", usage)
+	}
+	if _, err := balancer.SendEnvelope(context.Background(), env, strings.NewReader("hello\r\n")); !errors.Is(err, goemail.ErrQuotaExhausted) {
+		t.Errorf("err after restart = %v, want ErrQuotaExhausted", err)
+	}
+}
+
+func TestBalancerWeighted(t *testing.T) {
+	a := newFakeServer(t).Start()
+	b := newFakeServer(t).Start()
+	balancer, err := goemail.NewBalancer("",
+		&goemail.Account{Name: "a", SMTP: goemail.NewSMTP(a.Host(), a.Port(), "", "", "a@example.com"), Weight: 3},
+		&goemail.Account{Name: "b", SMTP: goemail.NewSMTP(b.Host(), b.Port(), "", "", "b@example.com"), Weight: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balancer.Strategy = goemail.Weighted
+
+	env := &goemail.Envelope{To: []string{"to@example.com"}}
+	for range 8 {
+		if _, err := balancer.SendEnvelope(context.Background(), env, strings.NewReader("hello\r\n")); err != nil {
+			t.Fatal("SendEnvelope:", err)
+		}
+	}
+	if len(a.Mails()) != 6 || len(b.Mails()) != 2 {
+		t.Errorf("sent a=%d b=%d, want 6 and 2", len(a.Mails()), len(b.Mails()))
+	}
+}
+
+func TestBalancerFailover(t *testing.T) {
+	busy := newFakeServer(t)
+	busy.Reject = map[string]string{"to@example.com": "421 4.7.0 too many messages"}
+	busy.Start()
+	ok := newFakeServer(t).Start()
+
+	balancer, err := goemail.NewBalancer("",
+		&goemail.Account{Name: "busy", SMTP: goemail.NewSMTP(busy.Host(), busy.Port(), "", "", "a@example.com"), DailyQuota: 10},
+		&goemail.Account{Name: "ok", SMTP: goemail.NewSMTP(ok.Host(), ok.Port(), "", "", "b@example.com")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := balancer.SendEnvelope(context.Background(),
+		&goemail.Envelope{To: []string{"to@example.com"}}, strings.NewReader("hello\r\n"))
+	if err != nil || name != "ok" {
+		t.Fatalf("name = %s, err = %v", name, err)
+	}
+	// 发送失败不占用配额
+	if usage := balancer.Usage(); usage[0].DailySent != 0 || usage[1].DailySent != 1 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestBalancerMessageError(t *testing.T) {
+	first := newFakeServer(t).Start()
+	second := newFakeServer(t).Start()
+	balancer, err := goemail.NewBalancer("",
+		&goemail.Account{Name: "first", SMTP: goemail.NewSMTP(first.Host(), first.Port(), "", "", "a@example.com")},
+		&goemail.Account{Name: "second", SMTP: goemail.NewSMTP(second.Host(), second.Port(), "", "", "b@example.com")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 附件在发送时无法读取，返回该错误，不再尝试其他账号
+	name := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(name, []byte("%PDF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m := goemail.NewMessage()
+	m.SetFrom("from@example.com", "")
+	m.SetTo([]string{"to@example.com"})
+	m.SetBody("text/plain", "see attachment")
+	if err := m.Attach(name); err != nil {
+		t.Fatal("attach:", err)
+	}
+	os.Remove(name)
+
+	if _, err := balancer.Send(false, m); !errors.Is(err, fs.ErrNotExist) || errors.Is(err, goemail.ErrNoRelayAvailable) {
+		t.Errorf("err = %v, want the attachment error", err)
+	}
+	if n := len(second.Commands()); n != 0 {
+		t.Errorf("second account received %d commands, want 0", n)
+	}
+
+	// 账号名称是状态文件中的键，不能重复
+	if _, err := goemail.NewBalancer("",
+		&goemail.Account{Name: "a", SMTP: goemail.NewSMTP(first.Host(), first.Port(), "", "", "a@example.com")},
+		&goemail.Account{Name: "a", SMTP: goemail.NewSMTP(second.Host(), second.Port(), "", "", "b@example.com")},
+	); err == nil {
+		t.Error("duplicate account names should be rejected")
+	}
+}
+
+func TestBalancerConcurrentSave(t *testing.T) {
+	server := newFakeServer(t).Start()
+	state := filepath.Join(t.TempDir(), "quota.json")
+	account := func() *goemail.Account {
+		return &goemail.Account{Name: "a", SMTP: goemail.NewSMTP(server.Host(), server.Port(), "", "", "a@example.com")}
+	}
+	balancer, err := goemail.NewBalancer(state, account())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	for range n {
+		wg.Go(func() {
+			if _, err := balancer.SendEnvelope(context.Background(),
+				&goemail.Envelope{To: []string{"to@example.com"}}, strings.NewReader("hello\r\n")); err != nil {
+				t.Error("SendEnvelope:", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	// 状态文件中是最后的计数
+	balancer, err = goemail.NewBalancer(state, account())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage := balancer.Usage(); usage[0].DailySent != n {
+		t.Errorf("usage after restart = %+v, want %d sent", usage, n)
+	}
+}
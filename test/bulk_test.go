@@ -0,0 +1,147 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func newBulkSender(t *testing.T, server *fakeServer, config goemail.BulkTemplateConfig) *goemail.BulkSender {
+	t.Helper()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool2(s, goemail.PoolConfig{MaxSize: 3})
+	t.Cleanup(func() { pool.Close() })
+
+	tmpl, err := goemail.ParseBulkTemplate(config)
+	if err != nil {
+		t.Fatal("parse template:", err)
+	}
+	return &goemail.BulkSender{Pool: pool, Template: tmpl}
+}
+
+func TestBulkSlice(t *testing.T) {
+	server := newFakeServer(t)
+	server.Reject = map[string]string{"bad@example.com": "550 5.1.1 no such user"}
+	server.Start()
+
+	type customer struct {
+		Name  string
+		Email string
+	}
+	sender := newBulkSender(t, server, goemail.BulkTemplateConfig{
+		From:    "from@example.com",
+		To:      "{{.Email}}",
+		Subject: "Hello {{.Name}}",
+		Text:    "Dear {{.Name}}",
+		HTML:    "<p>Dear {{.Name}}</p>",
+	})
+	var report bytes.Buffer
+	sender.Report = goemail.NewJSONReport(&report)
+
+	rows := []customer{
+		{"Alice", "alice@example.com"},
+		{"Bob", "bob@example.com"},
+		{"<Eve>", "eve@example.com"},
+		{"Mallory", "bad@example.com"},
+	}
+	sum, err := sender.Send(context.Background(), goemail.BulkFromSlice(rows))
+	if err != nil {
+		t.Fatal("send:", err)
+	}
+	if sum != (goemail.BulkSummary{Rows: 4, Sent: 3, Failed: 1}) {
+		t.Errorf("summary = %+v", sum)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 3 {
+		t.Fatalf("server received %d mails, want 3", len(mails))
+	}
+	for _, mail := range mails {
+		if mail.To[0] == "eve@example.com" && !strings.Contains(mail.Data, "&lt;Eve&gt;") {
+			t.Errorf("HTML body is not escaped:\n%s", mail.Data)
+		}
+		if mail.To[0] == "alice@example.com" && !strings.Contains(mail.Data, "Subject: Hello Alice") {
+			t.Errorf("subject is not rendered:\n%s", mail.Data)
+		}
+	}
+
+	var outcomes []goemail.BulkOutcome
+	scanner := bufio.NewScanner(&report)
+	for scanner.Scan() {
+		var o goemail.BulkOutcome
+		if err := json.Unmarshal(scanner.Bytes(), &o); err != nil {
+			t.Fatal(err)
+		}
+		outcomes = append(outcomes, o)
+	}
+	if len(outcomes) != 4 {
+		t.Fatalf("%d outcomes, want 4", len(outcomes))
+	}
+	i := slices.IndexFunc(outcomes, func(o goemail.BulkOutcome) bool { return o.Recipient == "bad@example.com" })
+	if i < 0 || outcomes[i].Row != 4 || outcomes[i].Status != goemail.BulkFailed || outcomes[i].Code != 550 {
+		t.Errorf("outcomes = %+v", outcomes)
+	}
+}
+
+func TestBulkCSV(t *testing.T) {
+	server := newFakeServer(t).Start()
+	sender := newBulkSender(t, server, goemail.BulkTemplateConfig{
+		From:    "from@example.com",
+		To:      "{{.email}}",
+		Subject: "Order {{.order}}",
+		Text:    "Hi {{.name}}, order {{.order}} has shipped.",
+	})
+	var report bytes.Buffer
+	sender.Report = goemail.NewCSVReport(&report)
+
+	name := filepath.Join(t.TempDir(), "customers.csv")
+	data := "\ufeffname, email, order\nAlice,alice@example.com,1001\nBob,bob@example.com,1002\nNobody,,1003\n"
+	if err := os.WriteFile(name, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := sender.Send(context.Background(), goemail.BulkFromCSVFile(name))
+	if err != nil {
+		t.Fatal("send:", err)
+	}
+	// 第 3 行没有收件人，渲染失败
+	if sum != (goemail.BulkSummary{Rows: 3, Sent: 2, Failed: 1}) {
+		t.Errorf("summary = %+v", sum)
+	}
+	if n := len(server.Mails()); n != 2 {
+		t.Errorf("server received %d mails, want 2", n)
+	}
+
+	records, err := csv.NewReader(&report).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0][0] != "row" {
+		t.Fatalf("report = %v", records)
+	}
+	i := slices.IndexFunc(records, func(r []string) bool { return r[0] == "3" })
+	if i < 0 || records[i][2] != "failed" || records[i][6] == "" {
+		t.Errorf("report = %v", records)
+	}
+
+	// 模板中缺少的列
+	tmpl, err := goemail.ParseBulkTemplate(goemail.BulkTemplateConfig{To: "{{.email}}", Text: "{{.missing}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(map[string]string{"email": "a@example.com"}); err == nil {
+		t.Error("render should fail on a missing column")
+	}
+
+	if _, err := sender.Send(context.Background(), goemail.BulkFromCSVFile(filepath.Join(t.TempDir(), "missing.csv"))); !os.IsNotExist(err) {
+		t.Errorf("err = %v, want file not found", err)
+	}
+}
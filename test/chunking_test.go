@@ -0,0 +1,79 @@
+package test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestChunking(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"PIPELINING", "CHUNKING"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.ChunkSize = 64
+	body := strings.Repeat("line\n", 50)
+	if err := s.DialAndSend1([]string{"to@example.com"}, []byte("Subject: Hi\r\n\r\n"+body)); err != nil {
+		t.Fatal("DialAndSend1:", err)
+	}
+
+	bdat := 0
+	for _, cmd := range server.Commands() {
+		if cmd == "DATA" {
+			t.Error("DATA must not be used when CHUNKING is advertised")
+		}
+		if strings.HasPrefix(cmd, "BDAT") {
+			bdat++
+		}
+	}
+	if bdat < 2 {
+		t.Errorf("sent %d BDAT commands, want several chunks", bdat)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("server received %d mails, want 1", len(mails))
+	}
+	if want := "Subject: Hi\r\n\r\n" + strings.ReplaceAll(body, "\n", "\r\n"); mails[0].Data != want {
+		t.Errorf("data = %q, want %q", mails[0].Data, want)
+	}
+}
+
+func TestBinaryMIME(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"CHUNKING", "BINARYMIME", "8BITMIME"}
+	server.Start()
+
+	raw := "\x00\x01binary\ncontent\xff"
+	msg := goemail.NewMessage()
+	msg.SetFrom("from@example.com", "")
+	msg.SetTo([]string{"to@example.com"})
+	msg.SetSubject("binary")
+	msg.SetBody("text/plain", "see attachment")
+	err := msg.Attach("../go.mod", goemail.Rename("data.bin"), goemail.SetCopyFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, raw)
+		return err
+	}))
+	if err != nil {
+		t.Fatal("Attach:", err)
+	}
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	if err := s.DialAndSend(true, msg); err != nil {
+		t.Fatal("DialAndSend:", err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("server received %d mails, want 1", len(mails))
+	}
+	if mails[0].Params != "BODY=BINARYMIME" {
+		t.Errorf("MAIL params = %q, want BODY=BINARYMIME", mails[0].Params)
+	}
+	if !strings.Contains(mails[0].Data, "Content-Transfer-Encoding: binary") || !strings.Contains(mails[0].Data, raw) {
+		t.Errorf("attachment was not sent unencoded:\n%s", mails[0].Data)
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestDialContextCanceled(t *testing.T) {
+	server := newFakeServer(t)
+	server.NoGreeting = true
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.DialContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DialContext error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("DialContext returned after %v, want about 200ms", d)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	server := newFakeServer(t)
+	server.NoGreeting = true
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.CommandTimeout = 200 * time.Millisecond
+
+	_, err := s.Dial()
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("Dial error = %v, want a timeout", err)
+	}
+}
+
+func TestSendContext(t *testing.T) {
+	server := newFakeServer(t)
+	server.DataDelay = 2 * time.Second
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+
+	msg := goemail.NewMessage()
+	msg.SetFrom("from@example.com", "")
+	msg.SetTo([]string{"to@example.com"})
+	msg.SetSubject("context")
+	msg.SetBody("text/plain", "Hello")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err = sender.SendContext(ctx, true, msg)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SendContext error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if sender.IsConnected() {
+		t.Error("sender should be disconnected after an interrupted transaction")
+	}
+
+	if err := s.DialAndSendContext(context.Background(), true, msg); err != nil {
+		t.Fatal("DialAndSendContext:", err)
+	}
+	mails := server.Mails()
+	if len(mails) == 0 || mails[len(mails)-1].To[0] != "to@example.com" {
+		t.Fatalf("server received %+v", mails)
+	}
+}
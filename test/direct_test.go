@@ -0,0 +1,170 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+// fakeResolver 返回固定的 MX 与 A/AAAA 记录。
+type fakeResolver struct {
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if mx, ok := r.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// mapDialer 将 "主机名:25" 映射到测试服务器的地址。
+type mapDialer map[string]string
+
+func (m mapDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if a, ok := m[address]; ok {
+		address = a
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", address)
+}
+
+// closedAddr 返回一个没有服务器监听的地址。
+func closedAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestDirectSender(t *testing.T) {
+	mx := newFakeServer(t).Start()
+	fallback := newFakeServer(t).Start()
+	reject := newFakeServer(t)
+	reject.Reject = map[string]string{"bob@c.test": "550 5.1.1 no such user"}
+	reject.Start()
+	backup := newFakeServer(t).Start()
+
+	d := goemail.NewDirectSender("mail.example.com")
+	d.Resolver = &fakeResolver{
+		mx: map[string][]*net.MX{
+			"a.test":    {{Host: "mx2.a.test.", Pref: 20}, {Host: "mx1.a.test.", Pref: 10}},
+			"c.test":    {{Host: "mx1.c.test.", Pref: 10}, {Host: "mx2.c.test.", Pref: 20}},
+			"null.test": {{Host: ".", Pref: 0}},
+		},
+		hosts: map[string][]string{"b.test": {"192.0.2.1"}},
+	}
+	dialer := mapDialer{
+		"mx1.a.test:25": closedAddr(t),
+		"mx2.a.test:25": mx.Addr(),
+		"b.test:25":     fallback.Addr(),
+		"mx1.c.test:25": reject.Addr(),
+		"mx2.c.test:25": backup.Addr(),
+	}
+	d.Configure = func(s *goemail.SMTP) { s.Dialer = dialer }
+
+	env := &goemail.Envelope{
+		From: "noreply@example.com",
+		To: []string{
+			"alice@a.test", "bob@c.test", "carol@B.test", "dave@a.test",
+			"erin@null.test", "frank@none.test",
+		},
+	}
+	results, err := d.SendEnvelope(context.Background(), env, strings.NewReader("Subject: hi\r\n\r\nhello\r\n"))
+	if err == nil {
+		t.Fatal("SendEnvelope should report the failed domains")
+	}
+
+	var domains []string
+	for _, r := range results {
+		domains = append(domains, r.Domain)
+	}
+	if got := strings.Join(domains, ","); got != "a.test,c.test,b.test,null.test,none.test" {
+		t.Fatalf("domains = %s", got)
+	}
+
+	// 第一个 MX 无法连接，投递到第二个
+	if r := results[0]; r.Err != nil || r.Host != "mx2.a.test" || len(r.Result.Accepted) != 2 {
+		t.Errorf("a.test: %+v", r)
+	}
+	if mails := mx.Mails(); len(mails) != 1 || strings.Join(mails[0].To, ",") != "alice@a.test,dave@a.test" {
+		t.Errorf("mx2.a.test received %+v", mails)
+	}
+	// 永久性错误，不再尝试下一个 MX
+	if r := results[1]; !goemail.IsPermanent(r.Err) || r.Host != "mx1.c.test" {
+		t.Errorf("c.test: %+v", r)
+	}
+	if len(backup.Commands()) != 0 {
+		t.Error("mx2.c.test should not be tried after a permanent failure")
+	}
+	// 没有 MX 记录，使用域名本身
+	if r := results[2]; r.Err != nil || r.Host != "b.test" {
+		t.Errorf("b.test: %+v", r)
+	}
+	if mails := fallback.Mails(); len(mails) != 1 || mails[0].To[0] != "carol@B.test" {
+		t.Errorf("b.test received %+v", mails)
+	}
+	if r := results[3]; !errors.Is(r.Err, goemail.ErrNullMX) {
+		t.Errorf("null.test: %v", r.Err)
+	}
+	if r := results[4]; !errors.Is(r.Err, goemail.ErrNoMailHost) {
+		t.Errorf("none.test: %v", r.Err)
+	}
+	if !errors.Is(err, goemail.ErrNullMX) || !errors.Is(err, goemail.ErrNoMailHost) {
+		t.Errorf("joined error = %v", err)
+	}
+}
+
+func TestDirectSenderFromAndPreference(t *testing.T) {
+	mx1 := newFakeServer(t).Start()
+	mx2 := newFakeServer(t).Start()
+	d := goemail.NewDirectSender("mail.example.com")
+	d.From = "bounce@example.com"
+	d.Resolver = &fakeResolver{mx: map[string][]*net.MX{
+		"a.test": {{Host: "mx1.a.test.", Pref: 10}, {Host: "mx2.a.test.", Pref: 10}},
+	}}
+	dialer := mapDialer{"mx1.a.test:25": mx1.Addr(), "mx2.a.test:25": mx2.Addr()}
+	d.Configure = func(s *goemail.SMTP) { s.Dialer = dialer }
+
+	// 信封中的发件人优先，为空时使用 DirectSender.From
+	for _, from := range []string{"noreply@example.com", ""} {
+		env := &goemail.Envelope{From: from, To: []string{"alice@a.test"}}
+		if _, err := d.SendEnvelope(context.Background(), env, strings.NewReader("hello\r\n")); err != nil {
+			t.Fatal("SendEnvelope:", err)
+		}
+	}
+	var froms []string
+	for _, m := range append(mx1.Mails(), mx2.Mails()...) {
+		froms = append(froms, m.From)
+	}
+	if !slices.Contains(froms, "noreply@example.com") || !slices.Contains(froms, "bounce@example.com") {
+		t.Errorf("envelope senders = %v", froms)
+	}
+
+	// 优先级相同的 MX 随机选择
+	for range 20 {
+		env := &goemail.Envelope{To: []string{"alice@a.test"}}
+		if _, err := d.SendEnvelope(context.Background(), env, strings.NewReader("hello\r\n")); err != nil {
+			t.Fatal("SendEnvelope:", err)
+		}
+	}
+	if len(mx1.Mails()) == 0 || len(mx2.Mails()) == 0 {
+		t.Errorf("mx1 received %d mails, mx2 %d; want both used", len(mx1.Mails()), len(mx2.Mails()))
+	}
+}
@@ -0,0 +1,188 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func newDispatcher(t *testing.T, server *fakeServer, poolSize int, config goemail.DispatcherConfig) *goemail.Dispatcher {
+	t.Helper()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool2(s, goemail.PoolConfig{MaxSize: poolSize})
+	t.Cleanup(func() { pool.Close() })
+	return goemail.NewDispatcher(pool, config)
+}
+
+func dispatcherMessage(to ...string) *goemail.Message {
+	m := goemail.NewMessage()
+	m.SetFrom("from@example.com", "")
+	m.SetTo(to)
+	m.SetSubject("async")
+	m.SetBody("text/plain", "hello")
+	return m
+}
+
+func TestDispatcherSubmit(t *testing.T) {
+	server := newFakeServer(t)
+	server.Reject = map[string]string{"bad@example.com": "550 5.1.1 no such user"}
+	server.Start()
+	d := newDispatcher(t, server, 2, goemail.DispatcherConfig{})
+
+	var callbacks atomic.Int32
+	var futures []*goemail.Future
+	for range 4 {
+		f, err := d.Submit(context.Background(), dispatcherMessage("to@example.com"))
+		if err != nil {
+			t.Fatal("submit:", err)
+		}
+		f.OnDone(func(*goemail.SendResult, error) { callbacks.Add(1) })
+		futures = append(futures, f)
+	}
+	partial, err := d.Submit(context.Background(), dispatcherMessage("to@example.com", "bad@example.com"))
+	if err != nil {
+		t.Fatal("submit:", err)
+	}
+
+	for _, f := range futures {
+		result, err := f.Wait(context.Background())
+		if err != nil || len(result.Accepted) != 1 {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+	}
+	select {
+	case <-partial.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("partial send is not done")
+	}
+	if result, err := partial.Result(); err != nil || len(result.Accepted) != 1 || len(result.PermFailed) != 1 {
+		t.Errorf("partial result = %+v, %v", result, err)
+	}
+
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal("shutdown:", err)
+	}
+	if n := callbacks.Load(); n != 4 {
+		t.Errorf("%d callbacks, want 4", n)
+	}
+	// 发送结束后注册的回调立即调用
+	called := false
+	futures[0].OnDone(func(*goemail.SendResult, error) { called = true })
+	if !called {
+		t.Error("callback registered after completion is not called")
+	}
+	if n := len(server.Mails()); n != 5 {
+		t.Errorf("server received %d mails, want 5", n)
+	}
+	if _, err := d.TrySubmit(dispatcherMessage("to@example.com")); !errors.Is(err, goemail.ErrDispatcherClosed) {
+		t.Errorf("err = %v, want ErrDispatcherClosed", err)
+	}
+}
+
+func TestDispatcherBackpressure(t *testing.T) {
+	server := newFakeServer(t)
+	server.DataDelay = 200 * time.Millisecond
+	server.Start()
+	d := newDispatcher(t, server, 1, goemail.DispatcherConfig{Workers: 1, BufferSize: 1})
+
+	first, err := d.Submit(context.Background(), dispatcherMessage("to@example.com"))
+	if err != nil {
+		t.Fatal("submit:", err)
+	}
+	time.Sleep(50 * time.Millisecond) // worker 取出第一封邮件
+	second, err := d.TrySubmit(dispatcherMessage("to@example.com"))
+	if err != nil {
+		t.Fatal("submit:", err)
+	}
+
+	// 缓冲区已满
+	if _, err := d.TrySubmit(dispatcherMessage("to@example.com")); !errors.Is(err, goemail.ErrDispatcherFull) {
+		t.Errorf("err = %v, want ErrDispatcherFull", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := d.Submit(ctx, dispatcherMessage("to@example.com")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Submit 等待缓冲区出现空位
+	third, err := d.Submit(context.Background(), dispatcherMessage("to@example.com"))
+	if err != nil {
+		t.Fatal("submit:", err)
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal("shutdown:", err)
+	}
+	for _, f := range []*goemail.Future{first, second, third} {
+		if _, err := f.Result(); err != nil {
+			t.Errorf("send: %v", err)
+		}
+	}
+}
+
+func TestDispatcherShutdownTimeout(t *testing.T) {
+	server := newFakeServer(t)
+	server.DataDelay = time.Second
+	server.Start()
+	d := newDispatcher(t, server, 1, goemail.DispatcherConfig{})
+
+	f, err := d.Submit(context.Background(), dispatcherMessage("to@example.com"))
+	if err != nil {
+		t.Fatal("submit:", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("shutdown took %v", elapsed)
+	}
+	if _, err := f.Result(); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestDispatcherShutdownBlockedSubmit(t *testing.T) {
+	server := newFakeServer(t)
+	server.DataDelay = time.Second
+	server.Start()
+	d := newDispatcher(t, server, 1, goemail.DispatcherConfig{Workers: 1, BufferSize: 1})
+
+	for range 2 { // 一封在发送中，一封在缓冲区
+		if _, err := d.Submit(context.Background(), dispatcherMessage("to@example.com")); err != nil {
+			t.Fatal("submit:", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := d.Submit(context.Background(), dispatcherMessage("to@example.com"))
+		blocked <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 缓冲区已满且有 Submit 在等待时，Shutdown 仍在 ctx 结束时返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("shutdown took %v", elapsed)
+	}
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, goemail.ErrDispatcherClosed) {
+			t.Errorf("blocked submit err = %v, want ErrDispatcherClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked submit does not return after Shutdown")
+	}
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func rcptCommands(server *fakeServer) []string {
+	var rcpts []string
+	for _, cmd := range server.Commands() {
+		if strings.HasPrefix(cmd, "RCPT") {
+			rcpts = append(rcpts, cmd)
+		}
+	}
+	return rcpts
+}
+
+func TestDSN(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"DSN"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.DSN = &goemail.DSN{
+		Return:            goemail.DSNReturnHeaders,
+		EnvelopeID:        "id=1 2",
+		Notify:            []goemail.DSNNotify{goemail.DSNNotifyFailure, goemail.DSNNotifyDelay},
+		OriginalRecipient: true,
+	}
+	if err := s.DialAndSend1([]string{"to@example.com"}, []byte("Subject: Hi\r\n\r\nHello")); err != nil {
+		t.Fatal("DialAndSend1:", err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("server received %d mails, want 1", len(mails))
+	}
+	if want := "RET=HDRS ENVID=id+3D1+202"; mails[0].Params != want {
+		t.Errorf("MAIL params = %q, want %q", mails[0].Params, want)
+	}
+	rcpts := rcptCommands(server)
+	if want := "RCPT TO:<to@example.com> NOTIFY=FAILURE,DELAY ORCPT=rfc822;to@example.com"; len(rcpts) != 1 || rcpts[0] != want {
+		t.Errorf("RCPT = %q, want %q", rcpts, want)
+	}
+
+	// Envelope.DSN 覆盖 SMTP.DSN
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+	env := &goemail.Envelope{
+		From: "from@example.com",
+		To:   []string{"other@example.com"},
+		DSN:  &goemail.DSN{Notify: []goemail.DSNNotify{goemail.DSNNotifyNever}},
+	}
+	if err := sender.SendEnvelope(context.Background(), env, goemail.NewMessage()); err != nil {
+		t.Fatal("SendEnvelope:", err)
+	}
+	rcpts = rcptCommands(server)
+	if want := "RCPT TO:<other@example.com> NOTIFY=NEVER"; rcpts[len(rcpts)-1] != want {
+		t.Errorf("RCPT = %q, want %q", rcpts[len(rcpts)-1], want)
+	}
+}
+
+func TestDSNUnsupported(t *testing.T) {
+	server := newFakeServer(t)
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.DSN = &goemail.DSN{Return: goemail.DSNReturnFull, Notify: []goemail.DSNNotify{goemail.DSNNotifySuccess}}
+	if err := s.DialAndSend1([]string{"to@example.com"}, []byte("Subject: Hi\r\n\r\nHello")); err != nil {
+		t.Fatal("DialAndSend1:", err)
+	}
+	if mails := server.Mails(); len(mails) != 1 || mails[0].Params != "" {
+		t.Errorf("DSN parameters must be omitted when the server lacks DSN: %+v", mails)
+	}
+
+	s.DSN = &goemail.DSN{Notify: []goemail.DSNNotify{goemail.DSNNotifyNever, goemail.DSNNotifySuccess}}
+	if err := s.DialAndSend1([]string{"to@example.com"}, []byte("Subject: Hi\r\n\r\nHello")); err == nil {
+		t.Error("NOTIFY=NEVER combined with SUCCESS should be rejected")
+	}
+}
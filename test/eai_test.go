@@ -0,0 +1,77 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func eaiMessage(to string) *goemail.Message {
+	msg := goemail.NewMessage()
+	msg.SetFrom("from@example.com", "")
+	msg.SetTo([]string{msg.FormatAddress(to, "张三")})
+	msg.SetSubject("你好")
+	msg.SetBody("text/plain", "Hello")
+	return msg
+}
+
+func TestSMTPUTF8(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"8BITMIME", "SMTPUTF8"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	if err := s.DialAndSend(true, eaiMessage("用户@例子.中国")); err != nil {
+		t.Fatal("DialAndSend:", err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("server received %d mails, want 1", len(mails))
+	}
+	mail := mails[0]
+	if mail.Params != "BODY=8BITMIME SMTPUTF8" {
+		t.Errorf("MAIL params = %q, want BODY=8BITMIME SMTPUTF8", mail.Params)
+	}
+	if len(mail.To) != 1 || mail.To[0] != "用户@例子.中国" {
+		t.Errorf("RCPT = %v", mail.To)
+	}
+	for _, h := range []string{"To: 张三 <用户@例子.中国>", "Subject: 你好"} {
+		if !strings.Contains(mail.Data, h) {
+			t.Errorf("data does not contain the unencoded header %q:\n%s", h, mail.Data)
+		}
+	}
+}
+
+func TestSMTPUTF8Fallback(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"8BITMIME"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	if err := s.DialAndSend(true, eaiMessage("user@例子.中国")); err != nil {
+		t.Fatal("DialAndSend:", err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("server received %d mails, want 1", len(mails))
+	}
+	const ace = "user@xn--fsqu00a.xn--fiqs8s"
+	if len(mails[0].To) != 1 || mails[0].To[0] != ace {
+		t.Errorf("RCPT = %v, want %s", mails[0].To, ace)
+	}
+	if strings.Contains(mails[0].Params, "SMTPUTF8") {
+		t.Error("SMTPUTF8 must not be sent when the server does not advertise it")
+	}
+	if !strings.Contains(mails[0].Data, "<"+ace+">") {
+		t.Errorf("To header was not converted to punycode:\n%s", mails[0].Data)
+	}
+
+	err := s.DialAndSend(true, eaiMessage("用户@例子.中国"))
+	if !errors.Is(err, goemail.ErrSMTPUTF8Unsupported) {
+		t.Errorf("DialAndSend error = %v, want %v", err, goemail.ErrSMTPUTF8Unsupported)
+	}
+}
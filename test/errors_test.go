@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestSMTPError(t *testing.T) {
+	for _, exts := range [][]string{nil, {"PIPELINING"}} {
+		server := newFakeServer(t)
+		server.Exts = exts
+		server.Reject = map[string]string{
+			"typo@example.com": "550 5.1.1 no such user",
+			"full@example.com": "452 4.2.2 mailbox full",
+		}
+		server.Start()
+
+		s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+		sender, err := s.Dial()
+		if err != nil {
+			t.Fatal("dial:", err)
+		}
+
+		err = sender.SendEmail("from@example.com", []string{"typo@example.com"}, goemail.NewMessage())
+		var smtpErr *goemail.SMTPError
+		if !errors.As(err, &smtpErr) {
+			t.Fatalf("%v: error %v is not an *SMTPError", exts, err)
+		}
+		want := goemail.SMTPError{Command: "RCPT", Code: 550, EnhancedCode: "5.1.1", Message: "no such user"}
+		if *smtpErr != want {
+			t.Errorf("%v: got %+v, want %+v", exts, *smtpErr, want)
+		}
+		if !goemail.IsPermanent(err) || goemail.IsTemporary(err) {
+			t.Errorf("%v: %v should be permanent", exts, err)
+		}
+
+		err = sender.SendEmail("from@example.com", []string{"full@example.com"}, goemail.NewMessage())
+		if !goemail.IsTemporary(err) || goemail.IsPermanent(err) {
+			t.Errorf("%v: %v should be temporary", exts, err)
+		}
+
+		result, _ := sender.SendPartial(context.Background(), &goemail.Envelope{
+			From: "from@example.com",
+			To:   []string{"a@example.com", "typo@example.com"},
+		}, goemail.NewMessage())
+		if st := result.PermFailed; len(st) != 1 || st[0].EnhancedCode != "5.1.1" || st[0].Message != "no such user" {
+			t.Errorf("%v: permanently failed = %+v", exts, st)
+		}
+		sender.Quit()
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"AUTH PLAIN"}
+	server.Users = map[string]string{"user": "secret"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "user", "wrong", "from@example.com")
+	s.AllowInsecureAuth = true
+	_, err := s.Dial()
+	if !goemail.IsAuthFailure(err) {
+		t.Fatalf("dial with a wrong password: got %v, want an auth failure", err)
+	}
+	var smtpErr *goemail.SMTPError
+	errors.As(err, &smtpErr)
+	if smtpErr.Command != "AUTH" || smtpErr.EnhancedCode != "5.7.8" {
+		t.Errorf("got %+v", smtpErr)
+	}
+
+	s = goemail.NewSMTP(server.Host(), server.Port(), "user", "secret", "from@example.com")
+	s.AllowInsecureAuth = true
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	sender.Quit()
+}
+
+func TestSentinelErrors(t *testing.T) {
+	s := goemail.NewSMTP("127.0.0.1", 25, "", "", "from@example.com")
+	err := goemail.NewSMTPSender(s).Send(true, goemail.NewMessage())
+	if !errors.Is(err, goemail.ErrNotConnected) {
+		t.Errorf("send without dialing: got %v, want ErrNotConnected", err)
+	}
+
+	server := newFakeServer(t).Start()
+	s = goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+
+	if err := sender.Send(true, goemail.NewMessage()); !errors.Is(err, goemail.ErrNoRecipients) {
+		t.Errorf("send without recipients: got %v, want ErrNoRecipients", err)
+	}
+
+	m := goemail.NewMessage()
+	m.SetTo([]string{"to@example.com"})
+	if err := sender.Send(false, m); !errors.Is(err, goemail.ErrMissingFrom) {
+		t.Errorf("send without From: got %v, want ErrMissingFrom", err)
+	}
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestFailoverSender(t *testing.T) {
+	busy := newFakeServer(t)
+	busy.Reject = map[string]string{"to@example.com": "451 4.3.0 try again later"}
+	busy.Start()
+	primary := newFakeServer(t).Start()
+
+	host, port, _ := net.SplitHostPort(closedAddr(t))
+	p, _ := strconv.Atoi(port)
+	f := goemail.NewFailoverSender(
+		&goemail.SMTPConfig{Host: host, Port: p, From: "from@example.com"},
+		&goemail.SMTPConfig{Host: busy.Host(), Port: busy.Port(), From: "from@example.com"},
+		&goemail.SMTPConfig{Host: primary.Host(), Port: primary.Port(), From: "from@example.com"},
+	)
+	f.Cooldown = 200 * time.Millisecond
+
+	env := &goemail.Envelope{To: []string{"to@example.com"}}
+	send := func() *goemail.FailoverResult {
+		t.Helper()
+		result, err := f.SendEnvelope(context.Background(), env, strings.NewReader("Subject: hi\r\n\r\nhello\r\n"))
+		if err != nil {
+			t.Fatal("SendEnvelope:", err)
+		}
+		return result
+	}
+
+	// 连接失败与 4xx 响应，都转移到下一个中继
+	result := send()
+	if result.Index != 2 || result.Relay != primary.Addr() || len(result.Errors) != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if !goemail.IsTemporary(result.Errors[busy.Addr()]) {
+		t.Errorf("busy relay error = %v", result.Errors[busy.Addr()])
+	}
+	if mails := primary.Mails(); len(mails) != 1 || mails[0].From != "from@example.com" {
+		t.Errorf("primary received %+v", mails)
+	}
+
+	// 熔断期间跳过失败的中继
+	n := len(busy.Commands())
+	if result := send(); result.Index != 2 || len(result.Errors) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if len(busy.Commands()) != n {
+		t.Error("relay with an open circuit should be skipped")
+	}
+	for _, st := range f.Relays()[:2] {
+		if st.Failures != 1 || st.OpenUntil.IsZero() {
+			t.Errorf("relay status = %+v", st)
+		}
+	}
+
+	// 冷却结束后再次尝试
+	time.Sleep(250 * time.Millisecond)
+	send()
+	if len(busy.Commands()) == n {
+		t.Error("relay should be retried after the cooldown")
+	}
+	if st := f.Relays()[1]; st.Failures != 2 {
+		t.Errorf("relay status = %+v", st)
+	}
+}
+
+func TestFailoverAuthAndPermanent(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"AUTH PLAIN"}
+	server.Users = map[string]string{"user": "secret"}
+	server.Reject = map[string]string{"bad@example.com": "550 5.1.1 no such user"}
+	server.Start()
+	backup := newFakeServer(t).Start()
+
+	wrong := goemail.NewSMTP(server.Host(), server.Port(), "user", "wrong", "from@example.com")
+	wrong.AllowInsecureAuth = true
+	ok := goemail.NewSMTP(server.Host(), server.Port(), "user", "secret", "from@example.com")
+	ok.AllowInsecureAuth = true
+	next := goemail.NewSMTP(backup.Host(), backup.Port(), "", "", "from@example.com")
+
+	// 认证失败，转移到下一个中继
+	f := goemail.NewFailoverSender1(wrong, next)
+	result, err := f.SendEnvelope(context.Background(),
+		&goemail.Envelope{To: []string{"to@example.com"}}, strings.NewReader("hello\r\n"))
+	if err != nil || result.Index != 1 {
+		t.Fatalf("result = %+v, err = %v", result, err)
+	}
+	if !goemail.IsAuthFailure(result.Errors[server.Addr()]) {
+		t.Errorf("auth error = %v", result.Errors[server.Addr()])
+	}
+
+	// 永久性错误，不再尝试下一个中继
+	f = goemail.NewFailoverSender1(ok, next)
+	n := len(backup.Mails())
+	_, err = f.SendEnvelope(context.Background(),
+		&goemail.Envelope{To: []string{"bad@example.com"}}, strings.NewReader("hello\r\n"))
+	if !goemail.IsPermanent(err) {
+		t.Errorf("err = %v, want a permanent error", err)
+	}
+	if len(backup.Mails()) != n {
+		t.Error("message rejected permanently should not be sent to the next relay")
+	}
+
+	// 所有中继都失败
+	f = goemail.NewFailoverSender1(wrong)
+	if _, err := f.SendEnvelope(context.Background(),
+		&goemail.Envelope{To: []string{"to@example.com"}}, strings.NewReader("hello\r\n")); !errors.Is(err, goemail.ErrNoRelayAvailable) {
+		t.Errorf("err = %v, want ErrNoRelayAvailable", err)
+	}
+}
+
+func TestFailoverMessageError(t *testing.T) {
+	first := newFakeServer(t).Start()
+	second := newFakeServer(t).Start()
+	f := goemail.NewFailoverSender1(
+		goemail.NewSMTP(first.Host(), first.Port(), "", "", "from@example.com"),
+		goemail.NewSMTP(second.Host(), second.Port(), "", "", "from@example.com"),
+	)
+
+	// 附件在发送时无法读取，是邮件本身的错误，不转移也不熔断
+	name := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(name, []byte("%PDF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m := goemail.NewMessage()
+	m.SetFrom("from@example.com", "")
+	m.SetTo([]string{"to@example.com"})
+	m.SetSubject("report")
+	m.SetBody("text/plain", "see attachment")
+	if err := m.Attach(name); err != nil {
+		t.Fatal("attach:", err)
+	}
+	os.Remove(name)
+
+	_, err := f.Send(false, m)
+	if !errors.Is(err, fs.ErrNotExist) || errors.Is(err, goemail.ErrNoRelayAvailable) {
+		t.Errorf("err = %v, want the attachment error", err)
+	}
+	if n := len(second.Commands()); n != 0 {
+		t.Errorf("second relay received %d commands, want 0", n)
+	}
+	for _, st := range f.Relays() {
+		if st.Failures != 0 || !st.OpenUntil.IsZero() {
+			t.Errorf("relay status = %+v", st)
+		}
+	}
+}
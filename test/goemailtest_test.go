@@ -0,0 +1,83 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+	"github.com/JiuYu77/go-email/goemailtest"
+)
+
+func TestGoemailtestParse(t *testing.T) {
+	attachment := filepath.Join(t.TempDir(), "invoice.txt")
+	if err := os.WriteFile(attachment, []byte("total: 42"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := goemail.NewMessage()
+	m.SetFrom("from@example.com", "发件人")
+	m.SetTo([]string{"张三 <zhang@example.com>"})
+	m.SetHeader("Cc", "cc@example.com")
+	m.SetHeader("Bcc", "bcc@example.com")
+	m.SetSubject("您的订单 #1001")
+	m.SetBody("text/plain", "您的验证码是 482913，10 分钟内有效。")
+	m.AddAlternative("text/html", "<p>您的验证码是 <b>482913</b></p>")
+	if err := m.Attach(attachment); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := goemailtest.NewTransport()
+	if err := transport.SendMessage(context.Background(), m); err != nil {
+		t.Fatal("send:", err)
+	}
+	goemailtest.AssertCount(t, transport, 1)
+
+	mail := goemailtest.AssertLast(t, transport)
+	goemailtest.AssertRecipients(t, mail, "zhang@example.com", "cc@example.com", "bcc@example.com")
+	goemailtest.AssertSubject(t, mail, "您的订单 #1001")
+	goemailtest.AssertBodyContains(t, mail, "10 分钟内有效")
+	goemailtest.AssertBodyContains(t, mail, "<b>482913</b>")
+	if mail.From != "from@example.com" || !slices.Equal(mail.To, []string{"zhang@example.com"}) ||
+		!slices.Equal(mail.Cc, []string{"cc@example.com"}) {
+		t.Errorf("from = %q, to = %v, cc = %v", mail.From, mail.To, mail.Cc)
+	}
+	if a := mail.Attachment("invoice.txt"); a == nil || string(a.Data) != "total: 42" || a.Inline {
+		t.Errorf("attachments = %+v", mail.Attachments)
+	}
+	if code := goemailtest.LastCode(t, transport, 6); code != "482913" {
+		t.Errorf("code = %q, want 482913", code)
+	}
+	if _, err := goemailtest.ExtractCode(mail, 8); err == nil {
+		t.Error("ExtractCode should fail without an 8-digit code")
+	}
+
+	transport.Reset()
+	goemailtest.AssertCount(t, transport, 0)
+	if transport.Last() != nil {
+		t.Error("Last should be nil after Reset")
+	}
+}
+
+func TestGoemailtestRaw(t *testing.T) {
+	transport := &goemailtest.Transport{From: "noreply@example.com"}
+	raw := "Subject: =?UTF-8?B?56Gu6K6k6ZO+5o6l?=\r\nTo: user@example.com\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n\r\n<a href=\"https://example.com/?token=abc\">confirm</a>"
+	env := &goemail.Envelope{To: []string{"user@example.com"}}
+	if err := transport.SendRaw(context.Background(), env, []byte(raw)); err != nil {
+		t.Fatal("send:", err)
+	}
+
+	mail := transport.Mails()[0]
+	goemailtest.AssertSubject(t, mail, "确认链接")
+	goemailtest.AssertBodyContains(t, mail, "token=abc")
+	if mail.Envelope.From != "noreply@example.com" || mail.Text != "" {
+		t.Errorf("mail = %+v", mail)
+	}
+	if err := transport.SendRaw(context.Background(), &goemail.Envelope{}, []byte(raw)); !errors.Is(err, goemail.ErrNoRecipients) {
+		t.Errorf("err = %v, want ErrNoRecipients", err)
+	}
+}
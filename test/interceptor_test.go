@@ -0,0 +1,165 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestInterceptorMessage(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+
+	var sent, failed int
+	var elapsed time.Duration
+	s.Use(
+		goemail.BeforeSend(func(ctx context.Context, req *goemail.SendRequest) error {
+			if req.Message != nil {
+				req.Message.SetHeader("X-Campaign", "spring")
+			}
+			return nil
+		}),
+		goemail.AfterSend(func(ctx context.Context, req *goemail.SendRequest, result *goemail.SendResult, err error, d time.Duration) {
+			if err != nil {
+				failed++
+				return
+			}
+			sent += len(result.Accepted)
+			elapsed += d
+		}),
+	)
+
+	m := goemail.NewMessage()
+	m.SetFrom("from@example.com", "")
+	m.SetTo([]string{"to@example.com"})
+	m.SetSubject("hello")
+	m.SetBody("text/plain", "hello")
+	if err := s.DialAndSend(false, m); err != nil {
+		t.Fatal("send:", err)
+	}
+	if err := s.DialAndSend1([]string{"a@example.com", "b@example.com"}, []byte("hello\r\n")); err != nil {
+		t.Fatal("send:", err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 2 || !strings.Contains(mails[0].Data, "X-Campaign: spring") {
+		t.Fatalf("server received %+v", mails)
+	}
+	if strings.Contains(mails[1].Data, "X-Campaign") {
+		t.Error("raw message should not be modified")
+	}
+	if sent != 3 || failed != 0 || elapsed <= 0 {
+		t.Errorf("sent = %d, failed = %d, elapsed = %v", sent, failed, elapsed)
+	}
+}
+
+func TestInterceptorChain(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+
+	var calls []string
+	trace := func(name string) goemail.Interceptor {
+		return func(ctx context.Context, req *goemail.SendRequest, next goemail.SendFunc) (*goemail.SendResult, error) {
+			calls = append(calls, name+" before")
+			result, err := next(ctx, req)
+			calls = append(calls, name+" after")
+			return result, err
+		}
+	}
+	errBlocked := errors.New("domain is blocked")
+	s.Use(trace("smtp"), goemail.BeforeSend(func(ctx context.Context, req *goemail.SendRequest) error {
+		for _, to := range req.Envelope.To {
+			if strings.HasSuffix(to, "@blocked.example") {
+				return errBlocked
+			}
+		}
+		// 抄送归档邮箱
+		req.Envelope.To = append(req.Envelope.To, "archive@example.com")
+		return nil
+	}))
+
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+	sender.Use(trace("sender"))
+
+	env := &goemail.Envelope{From: "from@example.com", To: []string{"to@example.com"}}
+	if err := sender.SendEnvelope(context.Background(), env, strings.NewReader("hello\r\n")); err != nil {
+		t.Fatal("send:", err)
+	}
+	want := []string{"smtp before", "sender before", "sender after", "smtp after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if len(env.To) != 1 {
+		t.Errorf("caller's envelope is modified: %v", env.To)
+	}
+	if mails := server.Mails(); len(mails) != 1 || !slices.Equal(mails[0].To, []string{"to@example.com", "archive@example.com"}) {
+		t.Errorf("server received %+v", mails)
+	}
+
+	// 拒绝发送
+	err = sender.SendEmail("from@example.com", []string{"x@blocked.example"}, strings.NewReader("hello\r\n"))
+	if !errors.Is(err, errBlocked) {
+		t.Errorf("err = %v, want errBlocked", err)
+	}
+	if n := len(server.Mails()); n != 1 {
+		t.Errorf("server received %d mails, want 1", n)
+	}
+}
+
+func TestInterceptorMessageCopy(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+
+	newMessage := func(subject string) *goemail.Message {
+		m := goemail.NewMessage()
+		m.SetFrom("from@example.com", "")
+		m.SetTo([]string{"to@example.com"})
+		m.SetSubject(subject)
+		m.SetBody("text/plain", subject)
+		return m
+	}
+	replacement := newMessage("replaced")
+	s.Use(goemail.BeforeSend(func(ctx context.Context, req *goemail.SendRequest) error {
+		// 每次发送都添加一个邮件头；替换为另一封邮件时只设置 Message
+		req.Message.SetHeader("X-Trace", append(req.Message.GetHeader("X-Trace"), "1")...)
+		if req.Message.GetHeader("Subject")[0] == "replace me" {
+			req.Message = replacement
+		}
+		return nil
+	}))
+
+	m := newMessage("hello")
+	for range 2 {
+		if err := s.DialAndSend(false, m); err != nil {
+			t.Fatal("send:", err)
+		}
+	}
+	if err := s.DialAndSend(false, newMessage("replace me")); err != nil {
+		t.Fatal("send:", err)
+	}
+
+	if h := m.GetHeader("X-Trace"); len(h) != 0 {
+		t.Errorf("interceptor modified the caller's message: X-Trace = %q", h)
+	}
+	mails := server.Mails()
+	if len(mails) != 3 {
+		t.Fatalf("server received %d mails, want 3", len(mails))
+	}
+	for i, mail := range mails[:2] {
+		if n := strings.Count(mail.Data, "X-Trace:"); n != 1 {
+			t.Errorf("mail %d has %d X-Trace headers, want 1:\n%s", i, n, mail.Data)
+		}
+	}
+	if !strings.Contains(mails[2].Data, "Subject: replaced") {
+		t.Errorf("replaced Message was not sent:\n%s", mails[2].Data)
+	}
+}
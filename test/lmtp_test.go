@@ -0,0 +1,158 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestLMTP(t *testing.T) {
+	for _, exts := range [][]string{nil, {"PIPELINING"}, {"CHUNKING"}} {
+		path := filepath.Join(t.TempDir(), "lmtp.sock")
+		server := newFakeServerOn(t, "unix", path)
+		server.LMTP = true
+		server.Exts = exts
+		server.LMTPReject = map[string]string{"full@example.com": "452 4.2.2 mailbox full"}
+		server.Start()
+
+		s := goemail.NewSMTP("localhost", 24, "", "", "from@example.com")
+		s.LMTP = true
+		s.UnixSocket = path
+		sender, err := s.Dial()
+		if err != nil {
+			t.Fatalf("%v: dial: %v", exts, err)
+		}
+
+		env := &goemail.Envelope{
+			From: "from@example.com",
+			To:   []string{"a@example.com", "full@example.com", "b@example.com"},
+		}
+		result, err := sender.SendPartial(context.Background(), env, goemail.NewMessage())
+		if err != nil {
+			t.Fatalf("%v: SendPartial: %v", exts, err)
+		}
+		if len(result.Accepted) != 2 || result.Accepted[1].Address != "b@example.com" {
+			t.Errorf("%v: accepted = %+v", exts, result.Accepted)
+		}
+		if st := result.TempFailed; len(st) != 1 || st[0].Address != "full@example.com" || st[0].EnhancedCode != "4.2.2" {
+			t.Errorf("%v: temporarily failed = %+v", exts, st)
+		}
+
+		// 任一收件人投递失败时，SendEnvelope 返回该收件人的错误
+		err = sender.SendEnvelope(context.Background(), env, goemail.NewMessage())
+		var smtpErr *goemail.SMTPError
+		if !errors.As(err, &smtpErr) || smtpErr.Code != 452 || !goemail.IsTemporary(err) {
+			t.Errorf("%v: SendEnvelope: got %v", exts, err)
+		}
+		// 邮件已投递给其他收件人，整封邮件不可重试
+		var partial *goemail.PartialDeliveryError
+		if !errors.As(err, &partial) || len(partial.Result.Accepted) != 2 || goemail.IsRetryable(err) {
+			t.Errorf("%v: SendEnvelope: got %v, want a non-retryable PartialDeliveryError", exts, err)
+		}
+
+		if !sender.IsConnected() {
+			t.Errorf("%v: connection should stay usable", exts)
+		}
+		if err := sender.Quit(); err != nil {
+			t.Errorf("%v: quit: %v", exts, err)
+		}
+		if mails := server.Mails(); len(mails) != 2 || len(mails[0].To) != 2 {
+			t.Errorf("%v: server received %+v", exts, mails)
+		}
+	}
+}
+
+func TestLMTPAuth(t *testing.T) {
+	server := newFakeServer(t)
+	server.LMTP = true
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "user", "secret", "from@example.com")
+	s.LMTP = true
+	if _, err := s.Dial(); !errors.Is(err, goemail.ErrLMTPAuth) {
+		t.Errorf("LMTP with credentials: got %v, want ErrLMTPAuth", err)
+	}
+}
+
+func TestLMTPPartialDeliveryNoRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lmtp.sock")
+	server := newFakeServerOn(t, "unix", path)
+	server.LMTP = true
+	server.LMTPReject = map[string]string{"full@example.com": "452 4.2.2 mailbox full"}
+	server.Start()
+
+	s := goemail.NewSMTP("localhost", 24, "", "", "from@example.com")
+	s.LMTP = true
+	s.UnixSocket = path
+	s.RetryPolicy = &goemail.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	// 重试整封邮件会使 a@example.com 收到重复的邮件
+	env := &goemail.Envelope{To: []string{"a@example.com", "full@example.com"}}
+	err := s.SendRaw(context.Background(), env, []byte("hello\r\n"))
+	var partial *goemail.PartialDeliveryError
+	if !errors.As(err, &partial) {
+		t.Fatalf("err = %v, want PartialDeliveryError", err)
+	}
+	if st := partial.Result.TempFailed; len(st) != 1 || st[0].Address != "full@example.com" {
+		t.Errorf("temporarily failed = %+v", st)
+	}
+	if n := len(server.Mails()); n != 1 {
+		t.Errorf("server received %d mails, want 1", n)
+	}
+}
+
+// closeConn 记录连接是否已被关闭。
+type closeConn struct {
+	net.Conn
+	closed *atomic.Bool
+}
+
+func (c closeConn) Close() error {
+	c.closed.Store(true)
+	return c.Conn.Close()
+}
+
+// closeDialer 建立 closeConn 连接。
+type closeDialer struct {
+	closed atomic.Bool
+}
+
+func (d *closeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return closeConn{Conn: conn, closed: &d.closed}, nil
+}
+
+func TestLMTPQuitHangup(t *testing.T) {
+	server := newFakeServer(t)
+	server.LMTP = true
+	server.HangupOnQuit = true
+	server.Start()
+
+	dialer := &closeDialer{}
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.LMTP = true
+	s.Dialer = dialer
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	if err := sender.Quit(); err == nil {
+		t.Error("Quit should fail when the server hangs up")
+	}
+	if !dialer.closed.Load() {
+		t.Error("connection is not closed after a failed QUIT")
+	}
+	if err := sender.Quit(); !errors.Is(err, goemail.ErrNotConnected) {
+		t.Errorf("second Quit: got %v, want ErrNotConnected", err)
+	}
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+// newTokenEndpoint 返回令牌端点的替身，第 n 次请求返回访问令牌 "token-n"。
+func newTokenEndpoint(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"bad refresh token"}`)
+			return
+		}
+		n := requests.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRefreshTokenSource(t *testing.T) {
+	for _, mech := range []string{goemail.SMTPAuthXOAuth2, goemail.SMTPAuthOAuthBearer} {
+		var requests atomic.Int32
+		endpoint := newTokenEndpoint(t, &requests)
+
+		server := newFakeServer(t)
+		server.Exts = []string{"AUTH " + mech}
+		server.Users = map[string]string{"user@example.com": "token-2"}
+		server.Start()
+
+		s := goemail.NewSMTP(server.Host(), server.Port(), "user@example.com", "", "")
+		s.AllowInsecureAuth = true
+		s.TokenSource = goemail.NewRefreshTokenSource(endpoint.URL, "client", "secret", "refresh")
+
+		// 第一个令牌被拒绝，令牌失效
+		if _, err := s.Dial(); !goemail.IsAuthFailure(err) {
+			t.Fatalf("%s: dial with a rejected token: got %v, want an auth failure", mech, err)
+		}
+		// 重新获取令牌后认证成功，之后使用缓存的令牌
+		for range 2 {
+			sender, err := s.Dial()
+			if err != nil {
+				t.Fatalf("%s: dial: %v", mech, err)
+			}
+			sender.Quit()
+		}
+		if n := requests.Load(); n != 2 {
+			t.Errorf("%s: token endpoint requested %d times, want 2", mech, n)
+		}
+	}
+}
+
+func TestRefreshTokenSourceError(t *testing.T) {
+	var requests atomic.Int32
+	endpoint := newTokenEndpoint(t, &requests)
+
+	ts := goemail.NewRefreshTokenSource(endpoint.URL, "client", "secret", "expired")
+	if _, err := ts.Token(); err == nil {
+		t.Error("Token should fail with an invalid refresh token")
+	}
+}
+
+func TestRefreshTokenSourceContext(t *testing.T) {
+	release := make(chan struct{})
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	t.Cleanup(endpoint.Close)
+
+	server := newFakeServer(t)
+	server.Exts = []string{"AUTH " + goemail.SMTPAuthXOAuth2}
+	server.Users = map[string]string{"user@example.com": "token"}
+	server.Start()
+
+	ts := goemail.NewRefreshTokenSource(endpoint.URL, "client", "secret", "refresh")
+	s := goemail.NewSMTP(server.Host(), server.Port(), "user@example.com", "", "")
+	s.AllowInsecureAuth = true
+	s.TokenSource = ts
+
+	// 令牌端点无响应时，DialContext 在 ctx 超时后返回
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.DialContext(ctx); err == nil {
+		t.Fatal("DialContext should fail while the token endpoint hangs")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("DialContext returned after %v, want about 200ms", d)
+	}
+
+	// 刷新期间不持有锁：其他调用者在各自的 ctx 结束时返回
+	done := make(chan error, 1)
+	go func() {
+		_, err := ts.Token()
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel2()
+	if _, err := ts.TokenContext(ctx2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TokenContext while another refresh is in progress: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if token, err := ts.TokenContext(context.Background()); err != nil || token != "token" {
+		t.Errorf("TokenContext = %q, %v; want the cached token", token, err)
+	}
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func pipeliningMessage() *goemail.Message {
+	msg := goemail.NewMessage()
+	msg.SetFrom("from@example.com", "")
+	msg.SetTo([]string{"a@example.com", "b@example.com"})
+	msg.SetHeader("Cc", "c@example.com")
+	msg.SetSubject("pipelining")
+	msg.SetBody("text/plain", "Hello")
+	return msg
+}
+
+func TestPipelining(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"PIPELINING"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	if err := s.DialAndSend(true, pipeliningMessage()); err != nil {
+		t.Fatal("DialAndSend:", err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("server received %d mails, want 1", len(mails))
+	}
+	if !mails[0].Pipelined {
+		t.Error("MAIL, RCPT and DATA were not pipelined")
+	}
+	if got := strings.Join(mails[0].To, ","); got != "a@example.com,b@example.com,c@example.com" {
+		t.Errorf("recipients = %s", got)
+	}
+}
+
+func TestPipeliningRejectedRcpt(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"PIPELINING"}
+	server.Reject = map[string]string{"b@example.com": "550 5.1.1 no such user"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	err = sender.Send(true, pipeliningMessage())
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("Send error = %v, want the 550 RCPT reply", err)
+	}
+	if len(server.Mails()) != 0 {
+		t.Error("message must not be delivered when a recipient is rejected")
+	}
+}
+
+func TestLockstepRejectedRcpt(t *testing.T) {
+	server := newFakeServer(t)
+	server.Reject = map[string]string{"b@example.com": "550 5.1.1 no such user"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+
+	if err := sender.Send(true, pipeliningMessage()); err == nil {
+		t.Fatal("Send should fail when a recipient is rejected")
+	}
+	// RSET 之后，同一连接仍可继续发送
+	if err := sender.Send1([]string{"a@example.com"}, []byte("Subject: Hi\r\n\r\nHello")); err != nil {
+		t.Fatal("Send1 after a failed transaction:", err)
+	}
+	for _, mail := range server.Mails() {
+		if mail.Pipelined {
+			t.Error("commands must not be pipelined without the PIPELINING extension")
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func poolSend(t *testing.T, pool *goemail.SMTPSenderPool) {
+	t.Helper()
+	sender, err := pool.Get()
+	if err != nil {
+		t.Fatal("get:", err)
+	}
+	if err := sender.SendEmail("from@example.com", []string{"to@example.com"}, strings.NewReader("hello\r\n")); err != nil {
+		t.Fatal("send:", err)
+	}
+	pool.Put(sender)
+}
+
+func TestPoolReuse(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool2(s, goemail.PoolConfig{MaxSize: 1, MaxMessages: 2})
+	defer pool.Close()
+
+	for range 3 {
+		poolSend(t, pool)
+	}
+	if len(server.Mails()) != 3 {
+		t.Errorf("server received %d mails, want 3", len(server.Mails()))
+	}
+	// 连接复用一次（RSET），第三封邮件超过 MaxMessages 后重新连接
+	if n := len(server.Clients()); n != 2 {
+		t.Errorf("%d connections, want 2", n)
+	}
+	if !slices.Contains(server.Commands(), "RSET") {
+		t.Error("connection should be reset with RSET before reuse")
+	}
+	if st := pool.Stats(); st.Open != 1 || st.Idle != 1 || st.InUse != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestPoolWait(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool2(s, goemail.PoolConfig{MaxSize: 1, MaxWait: 50 * time.Millisecond})
+	defer pool.Close()
+
+	sender, err := pool.Get()
+	if err != nil {
+		t.Fatal("get:", err)
+	}
+	if _, err := pool.Get(); !errors.Is(err, goemail.ErrPoolTimeout) {
+		t.Errorf("err = %v, want ErrPoolTimeout", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.GetContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+
+	// 连接放回后，等待中的 Get 取得该连接
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		pool.Put(sender)
+	}()
+	if got, err := pool.Get(); err != nil || got != sender {
+		t.Errorf("get = %p, %v; want the returned sender", got, err)
+	} else {
+		pool.Put(got)
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool2(s, goemail.PoolConfig{
+		MaxSize:             2,
+		MaxIdleTime:         150 * time.Millisecond,
+		HealthCheckInterval: 30 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	poolSend(t, pool)
+	time.Sleep(80 * time.Millisecond)
+	if st := pool.Stats(); st.Idle != 1 {
+		t.Errorf("healthy connection should stay idle, stats = %+v", st)
+	}
+	if !slices.Contains(server.Commands(), "NOOP") {
+		t.Error("idle connection should be checked with NOOP")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if st := pool.Stats(); st.Open != 0 || st.Idle != 0 {
+		t.Errorf("idle connection should be closed after MaxIdleTime, stats = %+v", st)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool1(2, s)
+
+	poolSend(t, pool) // 一个空闲连接
+	sender, err := pool.Get()
+	if err != nil {
+		t.Fatal("get:", err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close should wait for the sender in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := pool.Get(); !errors.Is(err, goemail.ErrPoolClosed) {
+		t.Errorf("err = %v, want ErrPoolClosed", err)
+	}
+
+	pool.Put(sender)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close should return after the sender is put back")
+	}
+	if st := pool.Stats(); st.Open != 0 {
+		t.Errorf("stats after close = %+v", st)
+	}
+	if n := strings.Count(strings.Join(server.Commands(), "\n"), "QUIT"); n != 1 {
+		t.Errorf("QUIT sent %d times, want 1", n)
+	}
+}
+
+func TestPoolStaleConnection(t *testing.T) {
+	server := newFakeServer(t)
+	server.HangupAfterReset = true // 连接放回后，服务器关闭空闲连接
+	server.Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool1(1, s)
+	defer pool.Close()
+
+	for i := range 3 {
+		if err := pool.SendRaw(context.Background(), &goemail.Envelope{To: []string{"to@example.com"}}, []byte("hello\r\n")); err != nil {
+			t.Fatalf("send %d: %v", i+1, err)
+		}
+	}
+	if n := len(server.Mails()); n != 3 {
+		t.Errorf("server received %d mails, want 3", n)
+	}
+	if n := len(server.Clients()); n != 3 {
+		t.Errorf("%d connections, want 3", n)
+	}
+}
+
+func TestPoolDoublePut(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool1(2, s)
+	defer pool.Close()
+
+	sender, err := pool.Get()
+	if err != nil {
+		t.Fatal("get:", err)
+	}
+	pool.Put(sender)
+	done := make(chan struct{})
+	go func() {
+		pool.Put(sender) // 重复放回被忽略
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("second Put blocks")
+	}
+	if st := pool.Stats(); st.Open != 1 || st.Idle != 1 || st.InUse != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestPoolHealthCheckLimit(t *testing.T) {
+	server := newFakeServer(t)
+	server.NoopDelay = 100 * time.Millisecond
+	server.Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool2(s, goemail.PoolConfig{MaxSize: 1, HealthCheckInterval: 10 * time.Millisecond})
+	defer pool.Close()
+
+	poolSend(t, pool)
+	// 健康检查期间，Get 等待被检查的连接，不建立超过 MaxSize 的连接
+	for range 5 {
+		time.Sleep(15 * time.Millisecond)
+		poolSend(t, pool)
+		if st := pool.Stats(); st.Open > 1 {
+			t.Fatalf("stats = %+v, open exceeds MaxSize", st)
+		}
+	}
+	if n := len(server.Clients()); n != 1 {
+		t.Errorf("%d connections, want 1", n)
+	}
+}
@@ -0,0 +1,261 @@
+package test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+// fakeProxy 是代理服务器的替身：接受连接，完成代理握手后把连接转发到 upstream，
+// 并记录客户端请求连接的目标地址。
+type fakeProxy struct {
+	ln       net.Listener
+	upstream string
+	// handshake 完成代理握手，返回客户端请求的目标地址；返回错误时关闭连接。
+	handshake func(c net.Conn, r *bufio.Reader) (string, error)
+
+	mtx     sync.Mutex
+	targets []string
+}
+
+func newFakeProxy(t *testing.T, upstream string, handshake func(net.Conn, *bufio.Reader) (string, error)) *fakeProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("listen:", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	p := &fakeProxy{ln: ln, upstream: upstream, handshake: handshake}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *fakeProxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+func (p *fakeProxy) Targets() []string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+func (p *fakeProxy) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	target, err := p.handshake(conn, r)
+	if err != nil {
+		return
+	}
+	p.mtx.Lock()
+	p.targets = append(p.targets, target)
+	p.mtx.Unlock()
+
+	up, err := net.Dial("tcp", p.upstream)
+	if err != nil {
+		return
+	}
+	defer up.Close()
+	go io.Copy(up, r)
+	io.Copy(conn, up)
+}
+
+// socks5Handshake 返回 SOCKS5 握手函数，username 不为空时要求用户名/密码认证。
+func socks5Handshake(username, password string) func(net.Conn, *bufio.Reader) (string, error) {
+	return socks5HandshakeReply(username, password, []byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+}
+
+// socks5HandshakeReply 与 socks5Handshake 相同，以 reply 回复 CONNECT 请求。
+func socks5HandshakeReply(username, password string, reply []byte) func(net.Conn, *bufio.Reader) (string, error) {
+	return func(c net.Conn, r *bufio.Reader) (string, error) {
+		head := make([]byte, 2)
+		if _, err := io.ReadFull(r, head); err != nil {
+			return "", err
+		}
+		if _, err := io.ReadFull(r, make([]byte, head[1])); err != nil {
+			return "", err
+		}
+		if username == "" {
+			c.Write([]byte{5, 0})
+		} else {
+			c.Write([]byte{5, 2})
+			ver := make([]byte, 2)
+			io.ReadFull(r, ver)
+			user := make([]byte, ver[1])
+			io.ReadFull(r, user)
+			n, _ := r.ReadByte()
+			pass := make([]byte, n)
+			io.ReadFull(r, pass)
+			if string(user) != username || string(pass) != password {
+				c.Write([]byte{1, 1})
+				return "", io.EOF
+			}
+			c.Write([]byte{1, 0})
+		}
+
+		req := make([]byte, 4)
+		if _, err := io.ReadFull(r, req); err != nil {
+			return "", err
+		}
+		var host string
+		switch req[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(r, ip)
+			host = net.IP(ip).String()
+		case 3:
+			n, _ := r.ReadByte()
+			name := make([]byte, n)
+			io.ReadFull(r, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		io.ReadFull(r, port)
+		c.Write(reply)
+		return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+	}
+}
+
+// connectHandshake 返回 HTTP CONNECT 握手函数，username 不为空时要求 Basic 认证。
+func connectHandshake(username, password string) func(net.Conn, *bufio.Reader) (string, error) {
+	return func(c net.Conn, r *bufio.Reader) (string, error) {
+		req, err := http.ReadRequest(r)
+		if err != nil {
+			return "", err
+		}
+		if username != "" {
+			want := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+			if req.Header.Get("Proxy-Authorization") != want {
+				io.WriteString(c, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+				return "", io.EOF
+			}
+		}
+		io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n")
+		return req.Host, nil
+	}
+}
+
+func TestProxyDialers(t *testing.T) {
+	server := newFakeServer(t).Start()
+
+	tests := []struct {
+		name   string
+		proxy  *fakeProxy
+		dialer func(addr string, password string) goemail.Dialer
+	}{
+		{
+			name:  "socks5",
+			proxy: newFakeProxy(t, server.Addr(), socks5Handshake("user", "secret")),
+			dialer: func(addr, password string) goemail.Dialer {
+				return goemail.NewSOCKS5Dialer(addr, "user", password)
+			},
+		},
+		{
+			name:  "http",
+			proxy: newFakeProxy(t, server.Addr(), connectHandshake("user", "secret")),
+			dialer: func(addr, password string) goemail.Dialer {
+				return goemail.NewHTTPProxyDialer(addr, "user", password)
+			},
+		},
+	}
+	for _, tt := range tests {
+		// 目标主机名由代理解析
+		s := goemail.NewSMTP("mail.example.test", 25, "", "", "from@example.com")
+		s.Dialer = tt.dialer(tt.proxy.Addr(), "secret")
+		sender, err := s.Dial()
+		if err != nil {
+			t.Fatalf("%s: dial: %v", tt.name, err)
+		}
+		if err := sender.Send1([]string{"to@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n")); err != nil {
+			t.Errorf("%s: send: %v", tt.name, err)
+		}
+		sender.Quit()
+		if targets := tt.proxy.Targets(); len(targets) != 1 || targets[0] != "mail.example.test:25" {
+			t.Errorf("%s: proxy targets = %v", tt.name, targets)
+		}
+
+		s.Dialer = tt.dialer(tt.proxy.Addr(), "wrong")
+		if _, err := s.Dial(); err == nil {
+			t.Errorf("%s: dial with a wrong proxy password should fail", tt.name)
+		}
+	}
+}
+
+func TestProxySOCKS5BadReply(t *testing.T) {
+	server := newFakeServer(t).Start()
+
+	for _, reply := range [][]byte{
+		{4, 0, 0, 1, 0, 0, 0, 0, 0, 0}, // 版本错误
+		{5, 0, 1, 1, 0, 0, 0, 0, 0, 0}, // RSV 不为 0
+	} {
+		proxy := newFakeProxy(t, server.Addr(), socks5HandshakeReply("", "", reply))
+		s := goemail.NewSMTP("mail.example.test", 25, "", "", "from@example.com")
+		s.Dialer = goemail.NewSOCKS5Dialer(proxy.Addr(), "", "")
+		sender, err := s.Dial()
+		if err == nil {
+			sender.Quit()
+			t.Errorf("reply %v: dial should fail", reply)
+			continue
+		}
+		if !strings.Contains(err.Error(), "socks5") {
+			t.Errorf("reply %v: err = %v, want a socks5 error", reply, err)
+		}
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	server := newFakeServer(t).Start()
+	proxy := newFakeProxy(t, server.Addr(), socks5Handshake("", ""))
+
+	d, err := goemail.NewProxyDialer("socks5://" + proxy.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := goemail.NewSMTP("127.0.0.1", 2525, "", "", "from@example.com")
+	s.Dialer = d
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	sender.Quit()
+	if targets := proxy.Targets(); len(targets) != 1 || targets[0] != "127.0.0.1:2525" {
+		t.Errorf("proxy targets = %v", targets)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smtp.sock")
+	server := newFakeServerOn(t, "unix", path).Start()
+
+	s := goemail.NewSMTP("localhost", 25, "", "", "from@example.com")
+	s.UnixSocket = path
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+	if err := sender.Send1([]string{"to@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n")); err != nil {
+		t.Fatal("send:", err)
+	}
+	if mails := server.Mails(); len(mails) != 1 {
+		t.Errorf("server received %d mails, want 1", len(mails))
+	}
+}
@@ -0,0 +1,305 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func newQueue(t *testing.T, server *fakeServer, config goemail.QueueConfig) *goemail.Queue {
+	t.Helper()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool2(s, goemail.PoolConfig{MaxSize: 2})
+	t.Cleanup(func() { pool.Close() })
+
+	if config.Dir == "" {
+		config.Dir = t.TempDir()
+	}
+	config.Pool = pool
+	if config.RetryPolicy == nil {
+		config.RetryPolicy = &goemail.RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}
+	}
+	config.PollInterval = 10 * time.Millisecond
+	q, err := goemail.NewQueue(config)
+	if err != nil {
+		t.Fatal("new queue:", err)
+	}
+	t.Cleanup(func() { q.Close(context.Background()) })
+	return q
+}
+
+// waitQueue 等待队列中没有待发送的邮件。
+func waitQueue(t *testing.T, q *goemail.Queue) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		entries, err := q.List(goemail.QueuePending)
+		if err != nil {
+			t.Fatal("list:", err)
+		}
+		if len(entries) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("queue is not drained")
+}
+
+func TestQueueDeliver(t *testing.T) {
+	server := newFakeServer(t).Start()
+	q := newQueue(t, server, goemail.QueueConfig{Workers: 2})
+
+	m := goemail.NewMessage()
+	m.SetFrom("from@example.com", "")
+	m.SetTo([]string{"to@example.com"})
+	m.SetSubject("queued")
+	m.SetBody("text/plain", "hello")
+	if _, err := q.Enqueue(m); err != nil {
+		t.Fatal("enqueue:", err)
+	}
+	for range 3 {
+		if _, err := q.EnqueueRaw("from@example.com", []string{"raw@example.com"}, []byte("hello\r\n")); err != nil {
+			t.Fatal("enqueue:", err)
+		}
+	}
+	waitQueue(t, q)
+
+	if n := len(server.Mails()); n != 4 {
+		t.Errorf("server received %d mails, want 4", n)
+	}
+	if _, err := q.EnqueueRaw("from@example.com", nil, []byte("hello\r\n")); !errors.Is(err, goemail.ErrNoRecipients) {
+		t.Errorf("err = %v, want ErrNoRecipients", err)
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	server := newFakeServer(t)
+	server.Greylist = 2
+	server.Start()
+	q := newQueue(t, server, goemail.QueueConfig{})
+
+	if _, err := q.EnqueueRaw("from@example.com", []string{"to@example.com"}, []byte("hello\r\n")); err != nil {
+		t.Fatal("enqueue:", err)
+	}
+	waitQueue(t, q)
+
+	if n := len(server.Mails()); n != 1 {
+		t.Errorf("server received %d mails, want 1", n)
+	}
+	if dead, _ := q.List(goemail.QueueDead); len(dead) != 0 {
+		t.Errorf("%d dead entries, want 0", len(dead))
+	}
+}
+
+func TestQueueDeadLetter(t *testing.T) {
+	server := newFakeServer(t)
+	server.Reject = map[string]string{"bad@example.com": "550 5.1.1 no such user"}
+	server.Start()
+
+	buried := make(chan *goemail.QueueEntry, 2)
+	q := newQueue(t, server, goemail.QueueConfig{
+		OnDead: func(e *goemail.QueueEntry) { buried <- e },
+	})
+
+	// 永久失败的收件人移入死信，其他收件人正常发送
+	if _, err := q.EnqueueRaw("from@example.com", []string{"to@example.com", "bad@example.com"}, []byte("hello\r\n")); err != nil {
+		t.Fatal("enqueue:", err)
+	}
+	var e *goemail.QueueEntry
+	select {
+	case e = <-buried:
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry is not moved to dead letters")
+	}
+	if !slices.Equal(e.To, []string{"bad@example.com"}) || e.LastError == "" {
+		t.Errorf("dead entry = %+v", e)
+	}
+	waitQueue(t, q)
+	if mails := server.Mails(); len(mails) != 1 || !slices.Equal(mails[0].To, []string{"to@example.com"}) {
+		t.Errorf("server received %+v", mails)
+	}
+
+	dead, err := q.List(goemail.QueueDead)
+	if err != nil || len(dead) != 1 || dead[0].ID != e.ID {
+		t.Fatalf("dead entries = %v, %v", dead, err)
+	}
+
+	// Retry 将死信移回队列，再次被拒绝后回到死信
+	if err := q.Retry(e.ID); err != nil {
+		t.Fatal("retry:", err)
+	}
+	var retried *goemail.QueueEntry
+	select {
+	case retried = <-buried:
+	case <-time.After(5 * time.Second):
+		t.Fatal("retried entry is not moved to dead letters")
+	}
+	if retried.ID != e.ID || retried.Attempts != 1 {
+		t.Errorf("retried dead entry = %+v", retried)
+	}
+
+	if n, err := q.Purge(goemail.QueueDead); n != 1 || err != nil {
+		t.Errorf("purge = %d, %v", n, err)
+	}
+	if dead, _ := q.List(goemail.QueueDead); len(dead) != 0 {
+		t.Errorf("%d dead entries after purge", len(dead))
+	}
+	if err := q.Retry(e.ID); !errors.Is(err, goemail.ErrQueueEntryNotFound) {
+		t.Errorf("err = %v, want ErrQueueEntryNotFound", err)
+	}
+}
+
+func TestQueuePersist(t *testing.T) {
+	dir := t.TempDir()
+	down := newFakeServer(t)
+	down.Greylist = 100
+	down.Start()
+
+	q := newQueue(t, down, goemail.QueueConfig{
+		Dir:         dir,
+		RetryPolicy: &goemail.RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour},
+	})
+	id, err := q.EnqueueRaw("from@example.com", []string{"to@example.com"}, []byte("hello\r\n"))
+	if err != nil {
+		t.Fatal("enqueue:", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entries, _ := q.List(goemail.QueuePending)
+		if len(entries) == 1 && entries[0].Attempts == 1 {
+			if !entries[0].NextAttempt.After(time.Now()) || entries[0].LastError == "" {
+				t.Errorf("entry = %+v", entries[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("entry is not attempted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatal("close:", err)
+	}
+	if _, err := q.EnqueueRaw("from@example.com", []string{"to@example.com"}, nil); !errors.Is(err, goemail.ErrQueueClosed) {
+		t.Errorf("err = %v, want ErrQueueClosed", err)
+	}
+
+	// 重新打开同一目录，邮件仍在队列中，Retry 立即发送
+	up := newFakeServer(t).Start()
+	q = newQueue(t, up, goemail.QueueConfig{Dir: dir})
+	entries, err := q.List(goemail.QueuePending)
+	if err != nil || len(entries) != 1 || entries[0].ID != id || entries[0].Attempts != 1 {
+		t.Fatalf("pending entries = %v, %v", entries, err)
+	}
+	if err := q.Retry(id); err != nil {
+		t.Fatal("retry:", err)
+	}
+	waitQueue(t, q)
+	if n := len(up.Mails()); n != 1 {
+		t.Errorf("server received %d mails, want 1", n)
+	}
+}
+
+func TestQueueRejectedTwice(t *testing.T) {
+	server := newFakeServer(t)
+	server.Reject = map[string]string{
+		"a@example.com": "451 4.2.0 mailbox busy",
+		"b@example.com": "550 5.1.1 no such user",
+		"c@example.com": "451 4.2.0 mailbox busy",
+	}
+	server.Start()
+
+	buried := make(chan *goemail.QueueEntry, 3)
+	q := newQueue(t, server, goemail.QueueConfig{
+		RetryPolicy: &goemail.RetryPolicy{MaxAttempts: 50, InitialBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond},
+		OnDead:      func(e *goemail.QueueEntry) { buried <- e },
+	})
+	id, err := q.EnqueueRaw("from@example.com", []string{"a@example.com", "b@example.com", "c@example.com"}, []byte("hello\r\n"))
+	if err != nil {
+		t.Fatal("enqueue:", err)
+	}
+
+	// 同一封邮件两次有收件人被永久拒绝，两封死信都保留
+	var first, second *goemail.QueueEntry
+	select {
+	case first = <-buried:
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry is not moved to dead letters")
+	}
+	server.SetReject("a@example.com", "550 5.2.1 mailbox disabled")
+	select {
+	case second = <-buried:
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry is not moved to dead letters again")
+	}
+	if !slices.Equal(first.To, []string{"b@example.com"}) || !slices.Equal(second.To, []string{"a@example.com"}) ||
+		first.ID == second.ID || first.ID == id || second.ID == id {
+		t.Errorf("dead entries = %+v, %+v", first, second)
+	}
+	dead, err := q.List(goemail.QueueDead)
+	if err != nil {
+		t.Fatal("list:", err)
+	}
+	for _, want := range []string{first.ID, second.ID} {
+		if !slices.ContainsFunc(dead, func(e *goemail.QueueEntry) bool { return e.ID == want }) {
+			t.Errorf("dead entry %s is lost, dead entries = %v", want, dead)
+		}
+	}
+}
+
+func TestQueueInvalidID(t *testing.T) {
+	server := newFakeServer(t).Start()
+	dir := t.TempDir()
+	q := newQueue(t, server, goemail.QueueConfig{Dir: filepath.Join(dir, "queue")})
+
+	// 队列目录之外的文件不能被 Retry 或 Purge 访问
+	outside := filepath.Join(dir, "secret.json")
+	if err := os.WriteFile(outside, []byte(`{"id":"secret","to":["x@example.com"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"../../secret", "../secret", "/etc/passwd", ""} {
+		if err := q.Retry(id); !errors.Is(err, goemail.ErrQueueEntryNotFound) {
+			t.Errorf("Retry(%q) = %v, want ErrQueueEntryNotFound", id, err)
+		}
+		if n, err := q.Purge(goemail.QueueDead, id); n != 0 || err != nil {
+			t.Errorf("Purge(%q) = %d, %v", id, n, err)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the queue directory: %v", err)
+	}
+}
+
+func TestQueueRawParams(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"8BITMIME", "SMTPUTF8"}
+	server.Start()
+	q := newQueue(t, server, goemail.QueueConfig{})
+
+	// ASCII 邮件不声明 8BITMIME；Reply-To 中的 UTF-8 地址需要 SMTPUTF8
+	ascii := []byte("Subject: hello\r\n\r\nhello\r\n")
+	eai := []byte("Reply-To: 用户@例子.中国\r\nSubject: hello\r\n\r\nhello\r\n")
+	for _, data := range [][]byte{ascii, eai} {
+		if _, err := q.EnqueueRaw("from@example.com", []string{"to@example.com"}, data); err != nil {
+			t.Fatal("enqueue:", err)
+		}
+		waitQueue(t, q)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 2 {
+		t.Fatalf("server received %d mails, want 2", len(mails))
+	}
+	if mails[0].Params != "" {
+		t.Errorf("ASCII mail: MAIL params = %q, want none", mails[0].Params)
+	}
+	if mails[1].Params != "BODY=8BITMIME SMTPUTF8" {
+		t.Errorf("EAI mail: MAIL params = %q, want BODY=8BITMIME SMTPUTF8", mails[1].Params)
+	}
+}
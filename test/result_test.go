@@ -0,0 +1,61 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestSendPartial(t *testing.T) {
+	for _, exts := range [][]string{nil, {"PIPELINING"}} {
+		server := newFakeServer(t)
+		server.Exts = exts
+		server.Reject = map[string]string{
+			"typo@example.com": "550 5.1.1 no such user",
+			"full@example.com": "452 4.2.2 mailbox full",
+		}
+		server.Start()
+
+		s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+		sender, err := s.Dial()
+		if err != nil {
+			t.Fatal("dial:", err)
+		}
+
+		env := &goemail.Envelope{
+			From: "from@example.com",
+			To:   []string{"a@example.com", "typo@example.com", "full@example.com"},
+		}
+		result, err := sender.SendPartial(context.Background(), env, goemail.NewMessage())
+		if err != nil {
+			t.Fatalf("%v: SendPartial: %v", exts, err)
+		}
+		if len(result.Accepted) != 1 || result.Accepted[0].Address != "a@example.com" || result.Accepted[0].Code != 250 {
+			t.Errorf("%v: accepted = %+v", exts, result.Accepted)
+		}
+		if len(result.PermFailed) != 1 || result.PermFailed[0].Address != "typo@example.com" || result.PermFailed[0].Code != 550 {
+			t.Errorf("%v: permanently failed = %+v", exts, result.PermFailed)
+		}
+		if len(result.TempFailed) != 1 || result.TempFailed[0].Address != "full@example.com" || result.TempFailed[0].Code != 452 {
+			t.Errorf("%v: temporarily failed = %+v", exts, result.TempFailed)
+		}
+		if mails := server.Mails(); len(mails) != 1 || len(mails[0].To) != 1 {
+			t.Errorf("%v: server received %+v", exts, mails)
+		}
+
+		// 全部收件人被拒绝
+		env.To = []string{"typo@example.com", "full@example.com"}
+		result, err = sender.SendPartial(context.Background(), env, goemail.NewMessage())
+		if err == nil {
+			t.Errorf("%v: SendPartial should fail when every recipient is rejected", exts)
+		}
+		if result == nil || len(result.Failed()) != 2 {
+			t.Errorf("%v: result = %+v", exts, result)
+		}
+		if !sender.IsConnected() {
+			t.Errorf("%v: connection should stay usable", exts)
+		}
+		sender.Quit()
+	}
+}
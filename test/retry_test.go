@@ -0,0 +1,118 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestRetryGreylist(t *testing.T) {
+	server := newFakeServer(t)
+	server.Greylist = 2
+	server.Start()
+
+	var attempts []goemail.RetryAttempt
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.RetryPolicy = &goemail.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		Jitter:         0.5,
+		OnAttempt:      func(a goemail.RetryAttempt) { attempts = append(attempts, a) },
+	}
+	if err := s.DialAndSend1([]string{"to@example.com"}, []byte("hello\r\n")); err != nil {
+		t.Fatal("send:", err)
+	}
+
+	if len(attempts) != 3 {
+		t.Fatalf("%d attempts, want 3", len(attempts))
+	}
+	for i, a := range attempts[:2] {
+		if !goemail.IsTemporary(a.Err) || !a.Retry || a.Delay <= 0 || a.Delay > 20*time.Millisecond<<i {
+			t.Errorf("attempt %d = %+v", a.Attempt, a)
+		}
+	}
+	if a := attempts[2]; a.Err != nil || a.Retry {
+		t.Errorf("last attempt = %+v", a)
+	}
+	// 灰名单的 451 不影响连接，重试使用同一个连接
+	if n := len(server.Clients()); n != 1 {
+		t.Errorf("%d connections, want 1", n)
+	}
+	if len(server.Mails()) != 1 {
+		t.Error("message should be delivered after retries")
+	}
+}
+
+func TestRetryRedial(t *testing.T) {
+	server := newFakeServer(t)
+	server.Hangup = 1
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.RetryPolicy = &goemail.RetryPolicy{InitialBackoff: time.Millisecond}
+	if err := s.DialAndSend1([]string{"to@example.com"}, []byte("hello\r\n")); err != nil {
+		t.Fatal("send:", err)
+	}
+	if n := len(server.Clients()); n != 2 {
+		t.Errorf("%d connections, want 2 (redial after the connection is lost)", n)
+	}
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	server := newFakeServer(t)
+	server.Greylist = 2
+	server.Reject = map[string]string{"bad@example.com": "550 5.1.1 no such user"}
+	server.Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+
+	// 达到最大尝试次数
+	n := 0
+	s.RetryPolicy = &goemail.RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		OnAttempt:      func(goemail.RetryAttempt) { n++ },
+	}
+	if err := s.DialAndSend1([]string{"to@example.com"}, []byte("hello\r\n")); !goemail.IsTemporary(err) || n != 2 {
+		t.Errorf("err = %v after %d attempts", err, n)
+	}
+
+	// 永久性错误不重试
+	n = 0
+	if err := s.DialAndSend1([]string{"bad@example.com"}, []byte("hello\r\n")); !goemail.IsPermanent(err) || n != 1 {
+		t.Errorf("err = %v after %d attempts", err, n)
+	}
+
+	// 等待重试时 ctx 结束
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := goemail.NewRetryPolicy(5).Do(ctx, func(context.Context) error {
+		return &goemail.SMTPError{Command: "MAIL", Code: 451}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&goemail.SMTPError{Code: 421}, true},
+		{&goemail.SMTPError{Code: 450}, true},
+		{&goemail.SMTPError{Code: 550}, false},
+		{goemail.ErrConnectionLost, true},
+		{goemail.ErrRateLimited, true},
+		{goemail.ErrTLSRequired, false},
+		{goemail.ErrMissingFrom, false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := goemail.IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
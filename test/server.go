@@ -0,0 +1,459 @@
+package test
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMail 是 fakeServer 收到的一封邮件。
+type fakeMail struct {
+	From string
+	To   []string
+	Data string
+	// Params MAIL 命令中地址之后的参数，如 "BODY=8BITMIME"。
+	Params string
+	// Pipelined 收到 MAIL 命令时，客户端是否已一并写出了后续命令。
+	Pipelined bool
+}
+
+// fakeServer 是运行在本机的 SMTP 服务器替身，
+// 用于在不连接真实 SMTP 服务器的情况下测试 smtp 包。
+type fakeServer struct {
+	ln net.Listener
+
+	// Exts EHLO 响应中宣告的扩展，如 "PIPELINING"、"SIZE 1024"。
+	Exts []string
+	// NoGreeting 为 true 时，连接后不发送问候，模拟无响应的服务器。
+	NoGreeting bool
+	// DataDelay 收到邮件内容后，延迟多久才回复。
+	DataDelay time.Duration
+	// Reject RCPT 命令的拒绝响应，收件人地址 -> 响应，如 "550 5.1.1 no such user"。
+	Reject map[string]string
+	// Users AUTH 接受的用户，用户名 -> 密码（XOAUTH2、OAUTHBEARER 为访问令牌）；
+	// 需同时在 Exts 中宣告支持的机制，如 "AUTH PLAIN SCRAM-SHA-256"。
+	Users map[string]string
+	// LMTP 为 true 时作为 LMTP 服务器：只接受 LHLO，邮件内容发送完毕后为每个收件人各回复一条响应。
+	LMTP bool
+	// LMTPReject LMTP 模式下，邮件内容发送完毕后对收件人的拒绝响应，收件人地址 -> 响应。
+	LMTPReject map[string]string
+	// TLSConfig 不为 nil 时支持 STARTTLS 命令；需同时在 Exts 中宣告 "STARTTLS"。
+	TLSConfig *tls.Config
+	// Hangup 收到前 Hangup 条 MAIL 命令时直接断开连接，模拟连接中断。
+	Hangup int
+	// Greylist 之后的前 Greylist 条 MAIL 命令回复 451，模拟灰名单。
+	Greylist int
+	// HangupAfterReset 为 true 时，回复 RSET 后断开连接，模拟服务器关闭空闲连接。
+	HangupAfterReset bool
+	// NoopDelay 收到 NOOP 命令后，延迟多久才回复。
+	NoopDelay time.Duration
+	// HangupOnQuit 为 true 时，收到 QUIT 后不回复，直接断开连接。
+	HangupOnQuit bool
+
+	mtx     sync.Mutex
+	cmds    []string
+	mails   []*fakeMail
+	clients []string
+	nmail   int // 已收到的 MAIL 命令数
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	return newFakeServerOn(t, "tcp", "127.0.0.1:0")
+}
+
+// newFakeServerOn 返回监听在 network、address 上的 fakeServer，如 "unix" 与套接字路径。
+func newFakeServerOn(t *testing.T, network, address string) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal("listen:", err)
+	}
+	s := &fakeServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// Start 开始接受连接，应在配置完 fakeServer 的字段后调用。
+func (s *fakeServer) Start() *fakeServer {
+	go func() {
+		for {
+			conn, err := s.ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) Host() string {
+	return s.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+func (s *fakeServer) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// Addr 返回监听地址，如 "127.0.0.1:2525"。
+func (s *fakeServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Commands 返回收到的全部命令行（不含邮件内容）。
+func (s *fakeServer) Commands() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string(nil), s.cmds...)
+}
+
+// Mails 返回已接收的邮件。
+func (s *fakeServer) Mails() []*fakeMail {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]*fakeMail(nil), s.mails...)
+}
+
+// SetReject 在服务器运行期间设置收件人 rcpt 的拒绝响应，见 Reject。
+func (s *fakeServer) SetReject(rcpt, reply string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.Reject == nil {
+		s.Reject = make(map[string]string)
+	}
+	s.Reject[rcpt] = reply
+}
+
+// Clients 返回每个连接的客户端 IP 地址，按连接顺序排列。
+func (s *fakeServer) Clients() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string(nil), s.clients...)
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		s.mtx.Lock()
+		s.clients = append(s.clients, addr.IP.String())
+		s.mtx.Unlock()
+	}
+	if s.NoGreeting {
+		conn.Read(make([]byte, 1)) // 等待客户端断开
+		return
+	}
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake ESMTP ready")
+
+	var mail *fakeMail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		s.mtx.Lock()
+		s.cmds = append(s.cmds, line)
+		s.mtx.Unlock()
+
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		if s.LMTP && (verb == "EHLO" || verb == "HELO") || !s.LMTP && verb == "LHLO" {
+			text.PrintfLine("500 5.5.1 command not recognized")
+			continue
+		}
+		switch verb {
+		case "EHLO", "LHLO":
+			lines := append([]string{"fake"}, s.Exts...)
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				text.PrintfLine("250%s%s", sep, l)
+			}
+		case "HELO":
+			text.PrintfLine("250 fake")
+		case "MAIL":
+			s.mtx.Lock()
+			s.nmail++
+			n := s.nmail
+			s.mtx.Unlock()
+			if n <= s.Hangup {
+				return
+			}
+			if n <= s.Hangup+s.Greylist {
+				text.PrintfLine("451 4.7.1 greylisted, try again later")
+				continue
+			}
+			mail = &fakeMail{
+				From:      pathOf(arg),
+				Params:    paramsOf(arg),
+				Pipelined: text.R.Buffered() > 0,
+			}
+			text.PrintfLine("250 2.1.0 OK")
+		case "RCPT":
+			if mail == nil {
+				text.PrintfLine("503 5.5.1 need MAIL first")
+				continue
+			}
+			rcpt := pathOf(arg)
+			s.mtx.Lock()
+			reply, ok := s.Reject[rcpt]
+			s.mtx.Unlock()
+			if ok {
+				text.PrintfLine("%s", reply)
+				continue
+			}
+			mail.To = append(mail.To, rcpt)
+			text.PrintfLine("250 2.1.5 OK")
+		case "DATA":
+			if mail == nil || len(mail.To) == 0 {
+				text.PrintfLine("503 5.5.1 need RCPT first")
+				continue
+			}
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.deliver(text, mail, string(data))
+			mail = nil
+		case "BDAT":
+			if mail == nil || len(mail.To) == 0 {
+				text.PrintfLine("503 5.5.1 need RCPT first")
+				continue
+			}
+			size, last := parseBDAT(arg)
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(text.R, chunk); err != nil {
+				return
+			}
+			mail.Data += string(chunk)
+			if !last {
+				text.PrintfLine("250 2.0.0 %d octets received", size)
+				continue
+			}
+			s.deliver(text, mail, mail.Data)
+			mail = nil
+		case "AUTH":
+			mech, resp, _ := strings.Cut(arg, " ")
+			var ok bool
+			switch strings.ToUpper(mech) {
+			case "PLAIN":
+				ok = s.checkPlain(resp)
+			case "SCRAM-SHA-256":
+				ok = s.checkScram(text, resp)
+			case "XOAUTH2", "OAUTHBEARER":
+				ok = s.checkBearer(text, resp)
+			default:
+				text.PrintfLine("504 5.5.4 unrecognized authentication type")
+				continue
+			}
+			if ok {
+				text.PrintfLine("235 2.7.0 authentication successful")
+			} else {
+				text.PrintfLine("535 5.7.8 authentication credentials invalid")
+			}
+		case "STARTTLS":
+			if s.TLSConfig == nil {
+				text.PrintfLine("454 4.7.0 TLS not available")
+				continue
+			}
+			text.PrintfLine("220 2.0.0 ready to start TLS")
+			tlsConn := tls.Server(conn, s.TLSConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			text = textproto.NewConn(tlsConn)
+			mail = nil
+		case "RSET":
+			mail = nil
+			text.PrintfLine("250 2.0.0 OK")
+			if s.HangupAfterReset {
+				return
+			}
+		case "NOOP":
+			time.Sleep(s.NoopDelay)
+			text.PrintfLine("250 2.0.0 OK")
+		case "QUIT":
+			if s.HangupOnQuit {
+				return
+			}
+			text.PrintfLine("221 2.0.0 bye")
+			return
+		default:
+			text.PrintfLine("502 5.5.2 command not recognized")
+		}
+	}
+}
+
+// checkPlain 检查 AUTH PLAIN 的初始响应 "\x00username\x00password"（base64 编码）。
+func (s *fakeServer) checkPlain(resp string) bool {
+	b, err := base64.StdEncoding.DecodeString(resp)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(string(b), "\x00")
+	if len(parts) != 3 {
+		return false
+	}
+	password, ok := s.Users[parts[1]]
+	return ok && password == parts[2]
+}
+
+// checkScram 完成 SCRAM-SHA-256 认证过程（RFC 5802），resp 为 client-first-message（base64 编码）。
+func (s *fakeServer) checkScram(text *textproto.Conn, resp string) bool {
+	clientFirst, err := base64.StdEncoding.DecodeString(resp)
+	if err != nil || !strings.HasPrefix(string(clientFirst), "n,,") {
+		return false
+	}
+	clientFirstBare := string(clientFirst[len("n,,"):])
+	attrs := scramAttrs(clientFirstBare)
+	password, ok := s.Users[attrs["n"]]
+	if !ok {
+		return false
+	}
+
+	salt := []byte("fake-salt")
+	serverFirst := "r=" + attrs["r"] + "server-nonce,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+	text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(serverFirst)))
+	line, err := text.ReadLine()
+	if err != nil {
+		return false
+	}
+	clientFinal, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return false
+	}
+	withoutProof, proof64, _ := strings.Cut(string(clientFinal), ",p=")
+	proof, _ := base64.StdEncoding.DecodeString(proof64)
+
+	salted, _ := pbkdf2.Key(sha256.New, password, salt, 4096, sha256.Size)
+	clientKey := hmacSHA256(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	authMessage := clientFirstBare + "," + serverFirst + "," + withoutProof
+	signature := hmacSHA256(storedKey[:], authMessage)
+	for i := range signature {
+		signature[i] ^= clientKey[i]
+	}
+	if !hmac.Equal(signature, proof) {
+		return false
+	}
+
+	serverSignature := hmacSHA256(hmacSHA256(salted, "Server Key"), authMessage)
+	text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("v="+base64.StdEncoding.EncodeToString(serverSignature))))
+	_, err = text.ReadLine()
+	return err == nil
+}
+
+// checkBearer 检查 XOAUTH2、OAUTHBEARER 的初始响应（base64 编码）中的用户与令牌，
+// 令牌无效时按 RFC 7628 发送 JSON 错误挑战，并等待客户端的回复。
+func (s *fakeServer) checkBearer(text *textproto.Conn, resp string) bool {
+	b, err := base64.StdEncoding.DecodeString(resp)
+	if err != nil {
+		return false
+	}
+	var user, token string
+	for _, field := range strings.Split(string(b), "\x01") {
+		switch {
+		case strings.HasPrefix(field, "user="): // XOAUTH2
+			user = field[len("user="):]
+		case strings.HasPrefix(field, "n,a="): // OAUTHBEARER
+			user = strings.TrimSuffix(field[len("n,a="):], ",")
+		case strings.HasPrefix(field, "auth=Bearer "):
+			token = field[len("auth=Bearer "):]
+		}
+	}
+	if want, ok := s.Users[user]; ok && want == token {
+		return true
+	}
+
+	challenge := `{"status":"401","schemes":"bearer","scope":"https://mail.example.com/"}`
+	text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+	text.ReadLine()
+	return false
+}
+
+func scramAttrs(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, field := range strings.Split(msg, ",") {
+		if k, v, ok := strings.Cut(field, "="); ok {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+func hmacSHA256(key []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
+
+// deliver 保存邮件并回复，回复前按 DataDelay 延迟。
+func (s *fakeServer) deliver(text *textproto.Conn, mail *fakeMail, data string) {
+	time.Sleep(s.DataDelay)
+	mail.Data = data
+	if !s.LMTP {
+		s.mtx.Lock()
+		s.mails = append(s.mails, mail)
+		s.mtx.Unlock()
+		text.PrintfLine("250 2.0.0 queued")
+		return
+	}
+
+	var delivered []string
+	for _, rcpt := range mail.To {
+		if reply, ok := s.LMTPReject[rcpt]; ok {
+			text.PrintfLine("%s", reply)
+			continue
+		}
+		delivered = append(delivered, rcpt)
+		text.PrintfLine("250 2.0.0 <%s> delivered", rcpt)
+	}
+	if len(delivered) > 0 {
+		mail.To = delivered
+		s.mtx.Lock()
+		s.mails = append(s.mails, mail)
+		s.mtx.Unlock()
+	}
+}
+
+// paramsOf 返回 "FROM:<a@b.c> PARAM" 中地址之后的参数。
+func paramsOf(arg string) string {
+	if end := strings.IndexByte(arg, '>'); end >= 0 {
+		return strings.TrimSpace(arg[end+1:])
+	}
+	return ""
+}
+
+// parseBDAT 解析 BDAT 命令的参数 "<size> [LAST]"。
+func parseBDAT(arg string) (size int, last bool) {
+	fields := strings.Fields(arg)
+	if len(fields) > 0 {
+		size, _ = strconv.Atoi(fields[0])
+	}
+	return size, len(fields) > 1 && strings.EqualFold(fields[1], "LAST")
+}
+
+// pathOf 从 "FROM:<a@b.c> PARAM" 或 "TO:<a@b.c>" 中提取地址。
+func pathOf(arg string) string {
+	start := strings.IndexByte(arg, '<')
+	end := strings.IndexByte(arg, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
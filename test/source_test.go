@@ -0,0 +1,112 @@
+package test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestSourceAddrs(t *testing.T) {
+	server := newFakeServer(t).Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.SourceAddrs = []goemail.SourceAddr{
+		{IP: net.ParseIP("127.0.0.1"), LocalName: "a.example.com"},
+		{IP: net.ParseIP("127.0.0.2"), LocalName: "b.example.com"},
+	}
+	for range 4 {
+		sender, err := s.Dial()
+		if err != nil {
+			t.Fatal("dial:", err)
+		}
+		sender.Quit()
+	}
+
+	want := []string{"127.0.0.1", "127.0.0.2", "127.0.0.1", "127.0.0.2"}
+	if got := server.Clients(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("client addresses = %v, want %v", got, want)
+	}
+	var helo []string
+	for _, cmd := range server.Commands() {
+		if name, ok := strings.CutPrefix(cmd, "EHLO "); ok {
+			helo = append(helo, name)
+		}
+	}
+	if got := strings.Join(helo, ","); got != "a.example.com,b.example.com,a.example.com,b.example.com" {
+		t.Errorf("EHLO names = %s", got)
+	}
+}
+
+func TestIPVersion(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+
+	for _, v := range []goemail.IPVersion{goemail.IPv4Only, goemail.PreferIPv6} {
+		s.IPVersion = v
+		sender, err := s.Dial()
+		if err != nil {
+			t.Fatalf("IPVersion %d: dial an IPv4 server: %v", v, err)
+		}
+		sender.Quit()
+	}
+
+	s.IPVersion = goemail.IPv6Only
+	if _, err := s.Dial(); err == nil {
+		t.Error("IPv6Only: dial an IPv4 server should fail")
+	}
+
+	// 没有与 IPv4 匹配的本地地址
+	s.IPVersion = goemail.IPv4Only
+	s.SourceAddrs = []goemail.SourceAddr{{IP: net.ParseIP("::1")}}
+	if _, err := s.Dial(); err == nil {
+		t.Error("IPv4Only with an IPv6 source address should fail")
+	}
+}
+
+func TestPoolSourceAddrs(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.SourceAddrs = []goemail.SourceAddr{
+		{IP: net.ParseIP("127.0.0.2")},
+		{IP: net.ParseIP("127.0.0.3")},
+	}
+
+	pool := goemail.NewSMTPSenderPool1(2, s)
+	defer pool.Close()
+	for range 2 {
+		sender, err := pool.Get()
+		if err != nil {
+			t.Fatal("get:", err)
+		}
+		defer pool.Put(sender)
+	}
+	if got := strings.Join(server.Clients(), ","); got != "127.0.0.2,127.0.0.3" {
+		t.Errorf("pool connections from %s", got)
+	}
+}
+
+func TestSourceAddrsCopy(t *testing.T) {
+	server := newFakeServer(t).Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.SourceAddrs = []goemail.SourceAddr{
+		{IP: net.ParseIP("127.0.0.1")},
+		{IP: net.ParseIP("127.0.0.2")},
+	}
+	// 复制的 SMTP 与原 SMTP 共享轮询顺序
+	c := *s
+	for _, d := range []*goemail.SMTP{s, &c, s, &c} {
+		sender, err := d.Dial()
+		if err != nil {
+			t.Fatal("dial:", err)
+		}
+		sender.Quit()
+	}
+
+	want := []string{"127.0.0.1", "127.0.0.2", "127.0.0.1", "127.0.0.2"}
+	if got := server.Clients(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("client addresses = %v, want %v", got, want)
+	}
+}
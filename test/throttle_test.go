@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+func TestThrottleBlock(t *testing.T) {
+	throttle := goemail.NewThrottle(goemail.RateLimit{MessagesPerSecond: 5}, goemail.ThrottleBlock)
+	to := []string{"to@example.com"}
+
+	start := time.Now()
+	for range 6 {
+		if err := throttle.Wait(context.Background(), "relay:25", to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 前 5 封为突发，第 6 封等待约 200ms
+	if d := time.Since(start); d < 150*time.Millisecond || d > time.Second {
+		t.Errorf("6 messages at 5/s took %v", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := throttle.Wait(ctx, "relay:25", to); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestThrottleDomain(t *testing.T) {
+	throttle := &goemail.Throttle{
+		Mode:    goemail.ThrottleFail,
+		Relays:  map[string]goemail.RateLimit{"slow:25": {MessagesPerSecond: 1}},
+		Domains: map[string]goemail.RateLimit{"a.test": {RecipientsPerMinute: 2}},
+	}
+	ctx := context.Background()
+
+	if err := throttle.Wait(ctx, "fast:25", []string{"x@a.test", "y@A.test", "z@b.test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Wait(ctx, "fast:25", []string{"w@a.test"}); !errors.Is(err, goemail.ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited", err)
+	}
+	// 被拒绝的请求不扣除配额，其他域名不受影响
+	if err := throttle.Wait(ctx, "fast:25", []string{"w@b.test"}); err != nil {
+		t.Errorf("b.test is not limited: %v", err)
+	}
+
+	if err := throttle.Wait(ctx, "slow:25", []string{"w@b.test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Wait(ctx, "slow:25", []string{"w@b.test"}); !errors.Is(err, goemail.ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestThrottleSend(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.Throttle = goemail.NewThrottle(goemail.RateLimit{RecipientsPerMinute: 3}, goemail.ThrottleFail)
+
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+
+	send := func(to ...string) error {
+		return sender.SendEmail("from@example.com", to, strings.NewReader("hello\r\n"))
+	}
+	if err := send("a@example.com", "b@example.com"); err != nil {
+		t.Fatal("send:", err)
+	}
+	if err := send("c@example.com", "d@example.com"); !errors.Is(err, goemail.ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited", err)
+	}
+	if err := send("c@example.com"); err != nil {
+		t.Errorf("send within the limit: %v", err)
+	}
+	if n := len(server.Mails()); n != 2 {
+		t.Errorf("server received %d mails, want 2", n)
+	}
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	goemail "github.com/JiuYu77/go-email"
+)
+
+// newTLSServer 返回支持 STARTTLS 的 fakeServer，证书为 127.0.0.1 的自签名证书；
+// 同时返回信任该证书的客户端配置与证书的 SHA-256 指纹。
+func newTLSServer(t *testing.T) (*fakeServer, *tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	sum := sha256.Sum256(der)
+
+	server := newFakeServer(t)
+	server.Exts = []string{"STARTTLS", "AUTH PLAIN"}
+	server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+	return server, &tls.Config{RootCAs: roots}, hex.EncodeToString(sum[:])
+}
+
+func TestTLSMandatory(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.TLSPolicy = goemail.TLSMandatory
+	if _, err := s.Dial(); !errors.Is(err, goemail.ErrTLSRequired) {
+		t.Errorf("dial a server without STARTTLS: got %v, want ErrTLSRequired", err)
+	}
+
+	server, cfg, _ := newTLSServer(t)
+	server.Users = map[string]string{"user": "secret"}
+	server.Start()
+	s = goemail.NewSMTP(server.Host(), server.Port(), "user", "secret", "from@example.com")
+	s.TLSPolicy = goemail.TLSMandatory
+	s.TLSConfig = cfg
+	s.MinTLSVersion = tls.VersionTLS12
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+	state, ok := sender.TLSConnectionState()
+	if !ok || state.Version < tls.VersionTLS12 {
+		t.Errorf("TLS state = %v, %v", state.Version, ok)
+	}
+}
+
+func TestNoTLS(t *testing.T) {
+	server, cfg, _ := newTLSServer(t)
+	server.Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.TLSPolicy = goemail.NoTLS
+	s.TLSConfig = cfg
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+	if _, ok := sender.TLSConnectionState(); ok {
+		t.Error("NoTLS should not use STARTTLS")
+	}
+}
+
+func TestTLSFingerprints(t *testing.T) {
+	server, _, fingerprint := newTLSServer(t)
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	s.TLSConfig = &tls.Config{InsecureSkipVerify: true} // 自签名证书，只校验指纹
+	s.TLSFingerprints = []string{fingerprint}
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial with a pinned certificate:", err)
+	}
+	sender.Quit()
+
+	s.TLSFingerprints = []string{"00:11:22"}
+	if _, err := s.Dial(); !errors.Is(err, goemail.ErrFingerprintMismatch) {
+		t.Errorf("dial with a wrong fingerprint: got %v, want ErrFingerprintMismatch", err)
+	}
+}
+
+func TestInsecureAuth(t *testing.T) {
+	server := newFakeServer(t)
+	server.Exts = []string{"AUTH PLAIN"}
+	server.Users = map[string]string{"user": "secret"}
+	server.Start()
+
+	s := goemail.NewSMTP(server.Host(), server.Port(), "user", "secret", "from@example.com")
+	if _, err := s.Dial(); !errors.Is(err, goemail.ErrInsecureAuth) {
+		t.Errorf("auth over plain text: got %v, want ErrInsecureAuth", err)
+	}
+
+	s.AllowInsecureAuth = true
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	sender.Quit()
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	goemail "github.com/JiuYu77/go-email"
+	"github.com/JiuYu77/go-email/goemailtest"
+)
+
+func TestTransportImplementations(t *testing.T) {
+	server := newFakeServer(t).Start()
+	s := goemail.NewSMTP(server.Host(), server.Port(), "", "", "from@example.com")
+	pool := goemail.NewSMTPSenderPool1(1, s)
+	defer pool.Close()
+	sender, err := s.Dial()
+	if err != nil {
+		t.Fatal("dial:", err)
+	}
+	defer sender.Quit()
+	failover := goemail.NewFailoverSender1(s)
+
+	m := goemail.NewMessage()
+	m.SetFrom("header@example.com", "")
+	m.SetTo([]string{"to@example.com"})
+	m.SetSubject("transport")
+	m.SetBody("text/plain", "hello")
+
+	transports := map[string]goemail.Transport{"SMTP": s, "SMTPSender": sender, "SMTPSenderPool": pool, "FailoverSender": failover}
+	for name, transport := range transports {
+		before := len(server.Mails())
+		if err := transport.SendMessage(context.Background(), m); err != nil {
+			t.Errorf("%s: SendMessage: %v", name, err)
+		}
+		// From 为空时使用配置的发件人
+		env := &goemail.Envelope{To: []string{"raw@example.com"}}
+		if err := transport.SendRaw(context.Background(), env, []byte("hello\r\n")); err != nil {
+			t.Errorf("%s: SendRaw: %v", name, err)
+		}
+
+		mails := server.Mails()[before:]
+		if len(mails) != 2 || mails[0].From != "header@example.com" || mails[1].From != "from@example.com" ||
+			!slices.Equal(mails[1].To, []string{"raw@example.com"}) {
+			t.Errorf("%s: server received %+v", name, mails)
+		}
+	}
+}
+
+func TestVerifierTransport(t *testing.T) {
+	transport := goemailtest.NewTransport()
+	cfg := &goemail.Config{}
+	cfg.From = "from@example.com"
+	v := goemail.NewVerifier1(cfg, transport)
+
+	code, err := v.SendVerificationCode("user@example.com", BuildVerificationCode)
+	if err != nil {
+		t.Fatal("send:", err)
+	}
+	m := goemailtest.AssertLast(t, transport)
+	goemailtest.AssertRecipients(t, m, "user@example.com")
+	goemailtest.AssertSubject(t, m, "验证码")
+	if m.Envelope.From != "from@example.com" {
+		t.Errorf("envelope from = %q", m.Envelope.From)
+	}
+	if got := goemailtest.LastCode(t, transport, len(code)); got != code {
+		t.Errorf("code in mail = %q, want %q", got, code)
+	}
+	if err := v.VerifyCode("user@example.com", code); err != nil {
+		t.Error("verify:", err)
+	}
+
+	transport.Fail(errors.New("transport is down"))
+	if _, err := v.SendVerificationCode("user@example.com", BuildVerificationCode); err == nil {
+		t.Error("send should fail when the transport fails")
+	}
+	goemailtest.AssertCount(t, transport, 1)
+}
@@ -18,6 +18,9 @@ func TestValidateFormat(t *testing.T) {
 		{"soramaix@QQ.com", true},
 		{"hjkjhk@645654.2121-6878.com.wcn", true},
 		{"xxxxxxxxx@wwew-163.com.cn", true},
+		{"用户@例子.中国", true},
+		{"张三@example.com", true},
+		{"user@例子.xn--fiqs8s", true},
 
 		{"441030517@QQ..com", false},
 		{"119941779@qq,com", false},
@@ -30,6 +33,8 @@ func TestValidateFormat(t *testing.T) {
 		{"2990814514@?￡QQ.COM", false},
 		{"xxxxxxxxx@___.com.cn", false},
 		{"xxxxxxxxx@wwew_163sadasdf.com.cn", false},
+		{"用户@例子。中国", false},
+		{"用户@@例子.中国", false},
 	}
 	for _, tc := range testcases {
 		r, err := goemail.ValidateFormat(tc.in)
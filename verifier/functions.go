@@ -8,11 +8,16 @@ import (
 )
 
 // 验证邮箱格式
+//
+// 支持国际化邮箱地址（RFC 6531），本地部分与域名可以包含 Unicode 字母和数字，
+// 如 用户@例子.中国；顶级域名也可以是 punycode 形式，如 xn--fiqs8s。
+//
 // @Return {bool} true 格式正确，false 格式错误
 func ValidateFormat(email string) (bool, error) {
 	// pattern := `^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,6}$`
 	// pattern := `^[a-zA-Z0-9_.-]+@[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.[a-zA-Z0-9]{2,6}$`
-	pattern := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,6}$`
+	// pattern := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,6}$`
+	pattern := `^[\p{L}\p{N}._%+-]+@[\p{L}\p{N}-]+(\.[\p{L}\p{N}-]+)*\.(\p{L}{2,6}|xn--[a-zA-Z0-9-]+)$`
 	matched, err := regexp.MatchString(pattern, email)
 	if err != nil {
 		return false, err
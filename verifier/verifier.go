@@ -1,6 +1,7 @@
 package verifier
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"time"
@@ -10,13 +11,22 @@ import (
 )
 
 type Verifier struct {
-	config *Config
-	cache  *cache.Cache[*VerificationCode]
-	sender *smtp.SMTPSender
-	mtx    sync.Mutex
+	config    *Config
+	cache     *cache.Cache[*VerificationCode]
+	transport smtp.Transport
+	mtx       sync.Mutex
 }
 
+// NewVerifier 创建 Verifier，使用 config.SMTPConfig 连接 SMTP 服务器发送邮件，每次发送建立新的连接。
 func NewVerifier(config *Config) *Verifier {
+	c := &config.SMTPConfig
+	return NewVerifier1(config, smtp.NewSMTP(c.Host, c.Port, c.Username, c.Password, c.From))
+}
+
+// NewVerifier1 创建 Verifier，经 transport 发送邮件，如 SMTPSenderPool、FailoverSender 或测试替身，
+// transport 由调用者管理（如关闭连接池）；
+// 发件人为 config.From，为空时使用 transport 配置的发件人。
+func NewVerifier1(config *Config, transport smtp.Transport) *Verifier {
 	if config.CodeExpiry <= 0 {
 		config.CodeExpiry = 5 * time.Minute
 	}
@@ -33,14 +43,9 @@ func NewVerifier(config *Config) *Verifier {
 	}
 
 	verifier := &Verifier{
-		config: config,
-		cache:  cache.NewCache[*VerificationCode](config.CacheCleanup),
-		sender: smtp.NewSMTPSender(
-			smtp.NewSMTP(
-				config.SMTPConfig.Host, config.SMTPConfig.Port,
-				config.SMTPConfig.Username, config.SMTPConfig.Password, config.SMTPConfig.From,
-			),
-		),
+		config:    config,
+		cache:     cache.NewCache[*VerificationCode](config.CacheCleanup),
+		transport: transport,
 	}
 	return verifier
 }
@@ -49,10 +54,8 @@ func (v *Verifier) sendEmail(email []string, msg []byte) error {
 	v.mtx.Lock()
 	defer v.mtx.Unlock()
 
-	if err := v.sender.Dial(); err != nil {
-		return err
-	}
-	return v.sender.Send1(email, msg)
+	env := &smtp.Envelope{From: v.config.From, To: email}
+	return v.transport.SendRaw(context.Background(), env, msg)
 }
 
 // 构建并发送邮件